@@ -0,0 +1,199 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenExpiryDelta is how long before its expiry a token is refreshed
+// in the background by the Auth option
+const DefaultTokenExpiryDelta = 30 * time.Second
+
+// Token is a credential returned by a TokenSource
+type Token struct {
+	AccessToken string
+	// TokenType is the scheme of the Authorization header, Bearer if empty
+	TokenType string
+	// Expiry is when the token expires, zero if it does not
+	Expiry time.Time
+}
+
+// header returns the value of the Authorization header for the token
+func (t Token) header() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// TokenSource returns tokens for the Auth option. It is called for a new token
+// when the cached one expires or a request is rejected with 401 Unauthorized.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenInvalidator is implemented by TokenSources caching tokens themselves.
+// The Auth option calls Invalidate with a token rejected with 401
+// Unauthorized before asking the source for a new one.
+type TokenInvalidator interface {
+	Invalidate(Token)
+}
+
+// TokenSourceFunc is like http.HandlerFunc, but for the TokenSource interface
+type TokenSourceFunc func(ctx context.Context) (Token, error)
+
+// Token satisfies the TokenSource interface
+func (f TokenSourceFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// tokenCache caches the token of a TokenSource. Concurrent requests needing a
+// new token share a single call to the source.
+type tokenCache struct {
+	source TokenSource
+	delta  time.Duration
+
+	mu    sync.Mutex // guards below
+	token Token
+	valid bool
+	call  *tokenCall // refresh in flight
+}
+
+// tokenCall is a call to the TokenSource shared by the requests waiting for it
+type tokenCall struct {
+	done  chan struct{}
+	token Token
+	err   error
+}
+
+func newTokenCache(source TokenSource) *tokenCache {
+	return &tokenCache{source: source, delta: DefaultTokenExpiryDelta}
+}
+
+// get returns the cached token, refreshing it first if it expired. A token
+// about to expire is still returned while it is refreshed in the background.
+func (tc *tokenCache) get(ctx context.Context) (Token, error) {
+	now := time.Now()
+	tc.mu.Lock()
+	if tc.valid && (tc.token.Expiry.IsZero() || now.Before(tc.token.Expiry)) {
+		token := tc.token
+		if !token.Expiry.IsZero() && now.After(token.Expiry.Add(-tc.delta)) {
+			tc.refresh(ctx)
+		}
+		tc.mu.Unlock()
+		return token, nil
+	}
+	call := tc.refresh(ctx)
+	tc.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return Token{}, ctx.Err()
+	}
+}
+
+// invalidate drops the cached token if it is the given one, so that the next
+// call to get fetches a new token
+func (tc *tokenCache) invalidate(token Token) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.token.AccessToken == token.AccessToken {
+		tc.valid = false
+	}
+	if inv, ok := tc.source.(TokenInvalidator); ok {
+		inv.Invalidate(token)
+	}
+}
+
+// refresh starts a call to the TokenSource unless one is in flight. The call
+// is not cancelled with ctx, as other requests may be waiting for it. It must
+// be called with tc.mu held.
+func (tc *tokenCache) refresh(ctx context.Context) *tokenCall {
+	if tc.call != nil {
+		return tc.call
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	tc.call = call
+	go func() {
+		call.token, call.err = tc.source.Token(context.WithoutCancel(ctx))
+		if call.err != nil {
+			call.err = fmt.Errorf("fetching token: %w", call.err)
+		}
+		tc.mu.Lock()
+		if call.err == nil {
+			tc.token, tc.valid = call.token, true
+		}
+		tc.call = nil
+		tc.mu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// authorize sets the Authorization header of req to the cached token
+func (c *client) authorize(req *http.Request) (Token, error) {
+	token, err := c.auth.get(req.Context())
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Authorization", token.header())
+	return token, nil
+}
+
+// retryAuth sends req, which was rejected with res, once more with a new
+// token. It returns the request and response to hand to the ResponseHandler.
+func (c *client) retryAuth(req *http.Request, res *http.Response, token Token) (*http.Request, *http.Response, error) {
+	retry, err := c.retryUnauthorized(req, res, token)
+	if err != nil {
+		return req, nil, err
+	}
+	if retry == nil {
+		return req, res, nil
+	}
+	c.hooks.retry(retry)
+	if c.logger.Enabled(retry.Context(), slog.LevelInfo) {
+		c.logger.LogAttrs(retry.Context(), slog.LevelInfo, LogRetry,
+			slog.String(LogKeyMethod, retry.Method),
+			slog.String(LogKeyURL, c.redact.URL(retry.URL)),
+			slog.Int(LogKeyAttempt, c.hooks.info(retry.Context(), retry.URL).Attempt),
+			slog.Int(LogKeyStatus, res.StatusCode))
+	}
+	if err := c.hooks.request(retry); err != nil {
+		return retry, nil, err
+	}
+	res, err = c.client.Do(retry)
+	return retry, res, err
+}
+
+// retryUnauthorized returns a copy of req to send again with a new token after
+// it was rejected with res, or nil if its body cannot be sent again
+func (c *client) retryUnauthorized(req *http.Request, res *http.Response, token Token) (*http.Request, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil, nil
+	}
+	// not closeBody, which cancels the request sent again here
+	io.CopyN(io.Discard, res.Body, c.maxDrainBytes)
+	res.Body.Close()
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	c.auth.invalidate(token)
+	if _, err := c.authorize(retry); err != nil {
+		return nil, err
+	}
+	return retry, nil
+}
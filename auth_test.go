@@ -0,0 +1,150 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	var mu sync.Mutex
+	current := "token-1"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		valid := r.Header.Get("Authorization") == "Bearer "+current
+		mu.Unlock()
+		if !valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer s.Close()
+
+	var calls int64
+	source := TokenSourceFunc(func(ctx context.Context) (Token, error) {
+		n := atomic.AddInt64(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return Token{AccessToken: fmt.Sprintf("token-%d", n), TokenType: "bearer"}, nil
+	})
+	var retries []int
+	c, err := New(Auth(source), OnRetry(func(req *http.Request, info HookInfo) {
+		retries = append(retries, info.Attempt)
+	}))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	post := func(body string, opts ...RequestOption) (int, string, error) {
+		var status int
+		var received string
+		rh := func(ctx context.Context, res *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(res.Body)
+			status, received = res.StatusCode, string(b)
+			return err
+		}
+		err := c.Post(context.Background(), rh, s.URL, strings.NewReader(body), opts...)
+		return status, received, err
+	}
+
+	// concurrent requests share a single call to the source
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, _, err := post("hello"); err != nil || status != http.StatusOK {
+				t.Errorf("expected 200, got %d %v", status, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt64(&calls); n != 1 {
+		t.Errorf("expected 1 call to the token source, got %d", n)
+	}
+
+	// the token is rotated, the rejected request is sent again with its body
+	mu.Lock()
+	current = "token-2"
+	mu.Unlock()
+	status, received, err := post("again")
+	if err != nil || status != http.StatusOK || received != "again" {
+		t.Errorf("expected the retry to succeed, got %d %q %v", status, received, err)
+	}
+	if len(retries) != 1 || retries[0] != 2 {
+		t.Errorf("expected OnRetry to be called for attempt 2, got %v", retries)
+	}
+
+	// retried only once
+	mu.Lock()
+	current = "revoked"
+	mu.Unlock()
+	if status, _, err := post("denied"); err != nil || status != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d %v", status, err)
+	}
+	if n := atomic.LoadInt64(&calls); n != 3 {
+		t.Errorf("expected 3 calls to the token source, got %d", n)
+	}
+
+	// the Authorization header of the request is kept
+	auth := SetHeaders(http.Header{"Authorization": {"Bearer revoked"}})
+	if status, _, err := post("own", auth); err != nil || status != http.StatusOK {
+		t.Errorf("expected 200 with the header of the request, got %d %v", status, err)
+	}
+	if n := atomic.LoadInt64(&calls); n != 3 {
+		t.Errorf("expected no call to the token source, got %d calls", n)
+	}
+
+	if _, err := New(Auth(nil)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	var calls int64
+	errSource := errors.New("auth server down")
+	expiry := time.Now().Add(10 * time.Second)
+	tc := newTokenCache(TokenSourceFunc(func(ctx context.Context) (Token, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if n == 3 {
+			return Token{}, errSource
+		}
+		return Token{AccessToken: fmt.Sprint(n), Expiry: expiry}, nil
+	}))
+	ctx := context.Background()
+
+	// expiring within the delta, refreshed in the background
+	token, err := tc.get(ctx)
+	if err != nil || token.AccessToken != "1" {
+		t.Fatalf("expected token 1, got %v %v", token, err)
+	}
+	token, err = tc.get(ctx)
+	if err != nil || token.AccessToken != "1" {
+		t.Fatalf("expected token 1 while refreshing, got %v %v", token, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	tc.mu.Lock()
+	refreshed := tc.token.AccessToken
+	tc.mu.Unlock()
+	if refreshed != "2" {
+		t.Errorf("expected the token to be refreshed in the background, got %s", refreshed)
+	}
+
+	// source errors are returned
+	tc.invalidate(Token{AccessToken: "2"})
+	if _, err := tc.get(ctx); !errors.Is(err, errSource) {
+		t.Errorf("expected %v, got %v", errSource, err)
+	}
+}
@@ -0,0 +1,261 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Fault describes failures injected by the Chaos option into the requests and
+// connections to a host. The request faults are applied in the order of the
+// fields, e.g. a Latency is added before a StatusCode is returned.
+type Fault struct {
+	// Host the fault applies to, either a host name or host:port. Empty
+	// applies to every host.
+	Host string
+	// Probability of injecting the fault into a request, and separately into
+	// a dial, between 0 (never) and 1 (always)
+	Probability float64
+
+	// Latency added before the request is sent
+	Latency time.Duration
+	// Reset fails the request with a connection reset error
+	Reset bool
+	// Timeout fails the request with an error whose Timeout method returns
+	// true, like a ResponseHeaderTimeout, once the ResponseHeaderTimeout of
+	// the client elapsed, or earlier with the error of its context when the
+	// request is canceled
+	Timeout bool
+	// StatusCode, if not zero, is returned with an empty body instead of
+	// sending the request
+	StatusCode int
+	// TruncateBody, if not zero, makes reading the response body fail with
+	// io.ErrUnexpectedEOF after that many bytes
+	TruncateBody int64
+
+	// The connection faults below are injected by the dialer of the client's
+	// own Transport, so they cannot be combined with WithRoundTripper.
+
+	// DialLatency is added before dialing a connection
+	DialLatency time.Duration
+	// DialError fails dials with a connection refused error
+	DialError bool
+	// SlowRead is added before every read from a connection
+	SlowRead time.Duration
+	// CloseAfterBytes, if not zero, closes connections once that many bytes
+	// were read from them, failing the read with a connection reset error
+	CloseAfterBytes int64
+}
+
+// requestFault reports whether f has faults to inject into requests
+func (f Fault) requestFault() bool {
+	return f.Latency > 0 || f.Reset || f.Timeout || f.StatusCode != 0 || f.TruncateBody > 0
+}
+
+// connFault reports whether f has faults to inject into connections
+func (f Fault) connFault() bool {
+	return f.DialLatency > 0 || f.DialError || f.SlowRead > 0 || f.CloseAfterBytes > 0
+}
+
+// matches reports whether the fault applies to addr, a host or host:port
+func (f Fault) matches(addr string) bool {
+	if f.Host == "" || f.Host == addr {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && f.Host == host
+}
+
+// chaosTimeoutError is the error of requests failed by a Timeout fault
+type chaosTimeoutError struct{}
+
+func (chaosTimeoutError) Error() string   { return "chaos: timeout awaiting response headers" }
+func (chaosTimeoutError) Timeout() bool   { return true }
+func (chaosTimeoutError) Temporary() bool { return true }
+
+// chaos injects the faults given with the Chaos option
+type chaos struct {
+	faults  []Fault
+	timeout time.Duration // of the Timeout faults
+	mu      sync.Mutex    // guards rand
+	rand    *rand.Rand
+}
+
+func newChaos(faults []Fault, seed int64, timeout time.Duration) *chaos {
+	return &chaos{faults: faults, timeout: timeout, rand: rand.New(rand.NewSource(seed))}
+}
+
+// roll returns the faults of the given kind to inject for addr
+func (c *chaos) roll(addr string, kind func(Fault) bool) []Fault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var faults []Fault
+	for _, f := range c.faults {
+		if !kind(f) || !f.matches(addr) {
+			continue
+		}
+		if c.rand.Float64() < f.Probability {
+			faults = append(faults, f)
+		}
+	}
+	return faults
+}
+
+// transport returns next with the request faults injected
+func (c *chaos) transport(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		faults := c.roll(req.URL.Host, Fault.requestFault)
+		var truncate int64
+		for _, f := range faults {
+			if err := sleep(req.Context(), f.Latency); err != nil {
+				closeRequestBody(req)
+				return nil, err
+			}
+			switch {
+			case f.Reset:
+				closeRequestBody(req)
+				return nil, resetError("read", req.URL.Host)
+			case f.Timeout:
+				closeRequestBody(req)
+				if err := sleep(req.Context(), c.timeout); err != nil {
+					return nil, err
+				}
+				return nil, chaosTimeoutError{}
+			case f.StatusCode != 0:
+				closeRequestBody(req)
+				return &http.Response{
+					Status:     strconv.Itoa(f.StatusCode) + " " + http.StatusText(f.StatusCode),
+					StatusCode: f.StatusCode,
+					Proto:      "HTTP/1.1",
+					ProtoMajor: 1,
+					ProtoMinor: 1,
+					Header:     make(http.Header),
+					Body:       http.NoBody,
+					Request:    req,
+				}, nil
+			}
+			if f.TruncateBody > 0 {
+				truncate = f.TruncateBody
+			}
+		}
+		res, err := next.RoundTrip(req)
+		if err != nil || truncate == 0 || res.Body == nil {
+			return res, err
+		}
+		res.Body = &truncatedBody{ReadCloser: res.Body, remaining: truncate}
+		return res, nil
+	})
+}
+
+// closeRequestBody closes the body of a request that is not sent, as a
+// RoundTripper must
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// dialer returns dial with the connection faults injected
+func (c *chaos) dialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cc := new(chaosConn)
+		for _, f := range c.roll(addr, Fault.connFault) {
+			if err := sleep(ctx, f.DialLatency); err != nil {
+				return nil, err
+			}
+			if f.DialError {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+			}
+			if f.SlowRead > cc.slowRead {
+				cc.slowRead = f.SlowRead
+			}
+			if f.CloseAfterBytes > 0 && (cc.closeAfter == 0 || f.CloseAfterBytes < cc.closeAfter) {
+				cc.closeAfter = f.CloseAfterBytes
+			}
+		}
+		conn, err := dial(ctx, network, addr)
+		if err != nil || (cc.slowRead == 0 && cc.closeAfter == 0) {
+			return conn, err
+		}
+		cc.Conn = conn
+		return cc, nil
+	}
+}
+
+// chaosConn slows down reads and closes the connection mid-stream
+type chaosConn struct {
+	net.Conn
+	slowRead   time.Duration
+	closeAfter int64
+	read       int64
+}
+
+func (c *chaosConn) Read(b []byte) (int, error) {
+	if c.slowRead > 0 {
+		time.Sleep(c.slowRead)
+	}
+	if c.closeAfter == 0 {
+		return c.Conn.Read(b)
+	}
+	if c.read >= c.closeAfter {
+		c.Conn.Close()
+		return 0, resetError("read", c.RemoteAddr().String())
+	}
+	if remaining := c.closeAfter - c.read; int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+	n, err := c.Conn.Read(b)
+	c.read += int64(n)
+	return n, err
+}
+
+// truncatedBody fails with io.ErrUnexpectedEOF once remaining bytes were read
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// resetError returns the error of a connection reset by the peer
+func resetError(op, addr string) error {
+	return &net.OpError{Op: op, Net: "tcp", Addr: chaosAddr(addr), Err: os.NewSyscallError(op, syscall.ECONNRESET)}
+}
+
+// chaosAddr is the net.Addr of the errors returned by injected faults
+type chaosAddr string
+
+func (a chaosAddr) Network() string { return "tcp" }
+func (a chaosAddr) String() string  { return string(a) }
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
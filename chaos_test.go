@@ -0,0 +1,199 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestChaos(t *testing.T) {
+	var hits int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	isTimeout := func(err error) bool {
+		var ne net.Error
+		return errors.As(err, &ne) && ne.Timeout()
+	}
+	tests := []struct {
+		name        string
+		fault       Fault
+		status      int
+		minDuration time.Duration
+		check       func(error) bool
+		hit         bool
+	}{
+		{"status code", Fault{Probability: 1, StatusCode: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, 0, nil, false},
+		{"reset", Fault{Probability: 1, Reset: true}, 0, 0, func(err error) bool { return errors.Is(err, syscall.ECONNRESET) }, false},
+		{"timeout", Fault{Probability: 1, Timeout: true}, 0, 50 * time.Millisecond, isTimeout, false},
+		{"latency", Fault{Probability: 1, Latency: 50 * time.Millisecond}, http.StatusOK, 50 * time.Millisecond, nil, true},
+		{"truncated body", Fault{Probability: 1, TruncateBody: 10}, http.StatusOK, 0, func(err error) bool { return errors.Is(err, io.ErrUnexpectedEOF) }, true},
+		{"other host", Fault{Host: "example.com", Probability: 1, StatusCode: http.StatusBadGateway}, http.StatusOK, 0, nil, true},
+		{"host name", Fault{Host: u.Hostname(), Probability: 1, StatusCode: http.StatusBadGateway}, http.StatusBadGateway, 0, nil, false},
+		{"dial error", Fault{Probability: 1, DialError: true}, 0, 0, func(err error) bool { return errors.Is(err, syscall.ECONNREFUSED) }, false},
+		{"dial latency", Fault{Probability: 1, DialLatency: 50 * time.Millisecond}, http.StatusOK, 50 * time.Millisecond, nil, true},
+		{"slow read", Fault{Probability: 1, SlowRead: 50 * time.Millisecond}, http.StatusOK, 50 * time.Millisecond, nil, true},
+		{"close mid-stream", Fault{Probability: 1, CloseAfterBytes: 200}, http.StatusOK, 0, func(err error) bool { return err != nil }, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{Chaos(tc.fault)}
+			if tc.fault.Timeout {
+				opts = append(opts, ResponseHeaderTimeout(50*time.Millisecond))
+			}
+			c, err := New(opts...)
+			if err != nil {
+				t.Fatalf("trouble when creating the client: %v", err)
+			}
+			defer c.Close()
+
+			var status int
+			rh := func(ctx context.Context, res *http.Response, err error) error {
+				if err != nil {
+					return err
+				}
+				status = res.StatusCode
+				_, err = io.ReadAll(res.Body)
+				return err
+			}
+			before := atomic.LoadInt64(&hits)
+			start := time.Now()
+			err = c.Get(context.Background(), rh, s.URL)
+			if d := time.Since(start); d < tc.minDuration {
+				t.Errorf("expected the request to take at least %s, took %s", tc.minDuration, d)
+			}
+			if tc.check == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tc.check != nil && !tc.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if status != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, status)
+			}
+			if hit := atomic.LoadInt64(&hits) > before; hit != tc.hit {
+				t.Errorf("expected the server to be hit: %t, got %t", tc.hit, hit)
+			}
+		})
+	}
+}
+
+func TestChaosClosesRequestBody(t *testing.T) {
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Error("expected the request not to be sent")
+		return nil, errors.New("sent")
+	})
+	for _, f := range []Fault{
+		{Probability: 1, Reset: true},
+		{Probability: 1, Timeout: true},
+		{Probability: 1, StatusCode: http.StatusServiceUnavailable},
+	} {
+		body := &closeTracker{Reader: strings.NewReader("payload")}
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com", body)
+		newChaos([]Fault{f}, 1, time.Millisecond).transport(next).RoundTrip(req)
+		if !body.closed {
+			t.Errorf("expected the request body to be closed for %+v", f)
+		}
+	}
+}
+
+func TestChaosTimeout(t *testing.T) {
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Error("expected the request not to be sent")
+		return nil, errors.New("sent")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	start := time.Now()
+	_, err := newChaos([]Fault{{Probability: 1, Timeout: true}}, 1, time.Hour).transport(next).RoundTrip(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("expected the request to wait for its context, took %s", d)
+	}
+}
+
+// closeTracker is a request body recording whether it was closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTracker) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestChaosProbability(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	c, err := New(ChaosSeed(1), Chaos(Fault{Probability: 0.5, StatusCode: http.StatusInternalServerError}))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	var failed int
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err == nil && res.StatusCode == http.StatusInternalServerError {
+			failed++
+		}
+		return err
+	}
+	for i := 0; i < 100; i++ {
+		if err := c.Get(context.Background(), rh, s.URL); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+	}
+	if failed < 30 || failed > 70 {
+		t.Errorf("expected about half of the requests to fail, got %d", failed)
+	}
+
+	// a zero probability never injects the fault
+	never, err := New(Chaos(Fault{StatusCode: http.StatusInternalServerError}))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer never.Close()
+	failed = 0
+	for i := 0; i < 10; i++ {
+		if err := never.Get(context.Background(), rh, s.URL); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+	}
+	if failed != 0 {
+		t.Errorf("expected no request to fail, got %d", failed)
+	}
+
+	for _, f := range []Fault{{Probability: -0.1}, {Probability: 1.1}, {TruncateBody: -1}} {
+		if _, err := New(Chaos(f)); err != ErrInvalidOptionValue {
+			t.Errorf("expected %v for %+v, got %v", ErrInvalidOptionValue, f, err)
+		}
+	}
+	_, err = New(WithRoundTripper(http.DefaultTransport), Chaos(Fault{Probability: 1, DialError: true}))
+	if !errors.Is(err, ErrInvalidOptionValue) {
+		t.Errorf("expected %v for connection faults with WithRoundTripper, got %v", ErrInvalidOptionValue, err)
+	}
+	c, err = New(WithRoundTripper(http.DefaultTransport), Chaos(Fault{Probability: 1, Latency: time.Millisecond}))
+	if err != nil {
+		t.Errorf("expected request faults to work with WithRoundTripper, got %v", err)
+	} else {
+		c.Close()
+	}
+}
@@ -2,14 +2,18 @@ package httpclient
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
@@ -28,6 +32,14 @@ const (
 	// DefaultKeepAliveTimeout - when socket keep alive check will be performed
 	DefaultKeepAliveTimeout = 90 * time.Second
 
+	// DefaultMaxDrainBytes of an unread response body to discard so that the
+	// connection can be reused
+	DefaultMaxDrainBytes = 64 << 10
+
+	// maxDrainDuration after which the drain of a response body still being
+	// produced, e.g. an endless stream, is given up and the request canceled
+	maxDrainDuration = 100 * time.Millisecond
+
 	// log-prefix
 	defaultLogPrefix = "[httpclient]: "
 )
@@ -46,6 +58,9 @@ type Client interface {
 	// Close all Idle connections
 	Close()
 
+	// Stats returns a snapshot of the connection pool counters
+	Stats() Stats
+
 	// HTTP Request Methods //
 
 	// Do is the generic HTTP request method. The two string parameters in
@@ -68,25 +83,50 @@ var _ Client = &client{}
 // timeouts and watch the resource use
 // safe (and intended) to use from several go routines
 type client struct {
+	auth                  *tokenCache
+	chaos                 *chaos
+	chaosFaults           []Fault
+	chaosSeed             int64
 	client                *http.Client
+	clientCert            *certReloader
+	closing               int32
+	connCloseFunc         func(ConnCloseEvent)
+	connStats             connStats
+	credentials           []credentialHeader
 	currentConnID         int64
+	debugWriter           io.Writer
+	digest                *digestAuth
 	customRoundTripper    http.RoundTripper
 	dialTimeout           time.Duration
 	disableHTTP2          bool
 	disableKeepAlive      bool
+	dumpBodyLimit         int64
+	harRecorders          []*HARRecorder
 	headers               http.Header
+	hooks                 hooks
 	idleConnTimeout       time.Duration
 	keepAliveTimeout      time.Duration
 	log                   *log.Logger
+	logCurl               bool
+	logger                *slog.Logger
 	logPrefix             string
 	logWriter             io.Writer
+	maxDrainBytes         int64
 	maxIdleConns          int
 	maxIdleConnsPerHost   int
+	metrics               MetricsRecorder
+	metricsRecorders      []MetricsRecorder
+	middlewares           []Middleware
+	redact                *redactor
 	redirectFunc          func(*http.Request, []*http.Request) error
 	responseHeaderTimeout time.Duration
+	rootCAs               *x509.CertPool
+	tlsConfig             *tls.Config
 	tlsHandshakeTimeout   time.Duration
 	transport             *http.Transport
-	withTracing           bool
+	tracing               *tracingConfig
+	unregister            []func() // called once by Close
+	unregisterOnce        sync.Once
 }
 
 // Client returns the *http.Client as it was initialized by the constructor
@@ -94,10 +134,24 @@ func (c *client) Client() *http.Client {
 	return c.client
 }
 
-// Close is a cleanup function that makes the client close any idle connections
+// Close is a cleanup function that makes the client close any idle
+// connections. The pool Stats of the client are no longer exported by its
+// metrics recorders afterwards, but the counters they exported keep their
+// totals.
 func (c *client) Close() {
-	c.log.Printf("Close client %#v with all idle connections", c)
+	stats := c.Stats()
+	c.logger.Info(LogClientClosed,
+		slog.Int64("open_conns", stats.Open),
+		slog.Int64("idle_conns", stats.Idle),
+		slog.Int64("active_conns", stats.Active))
+	atomic.StoreInt32(&c.closing, 1)
+	defer atomic.StoreInt32(&c.closing, 0)
 	c.transport.CloseIdleConnections()
+	c.unregisterOnce.Do(func() {
+		for _, unregister := range c.unregister {
+			unregister()
+		}
+	})
 }
 
 // New configures and returns a new instance of Client
@@ -118,17 +172,21 @@ func newDefaultClient() *client {
 
 // set sensible default values on the *client
 func (c *client) setDefaults() {
+	c.chaosSeed = time.Now().UnixNano()
 	c.dialTimeout = DefaultDialTimeout
+	c.dumpBodyLimit = DefaultDumpBodyLimit
 	c.headers = make(http.Header)
 	c.keepAliveTimeout = DefaultKeepAliveTimeout
 	c.log = log.New(
 		ioutil.Discard,
 		c.logPrefix,
-		log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile|log.LUTC,
+		log.Ldate|log.Ltime|log.Lmicroseconds|log.LUTC,
 	)
 	c.logPrefix = defaultLogPrefix
+	c.maxDrainBytes = DefaultMaxDrainBytes
 	c.maxIdleConns = DefaultMaxIdleConns
 	c.maxIdleConnsPerHost = -1 //-1 means unset
+	c.redact = newRedactor()
 	c.redirectFunc = defaultRedirectPolicy
 	c.responseHeaderTimeout = DefaultResponseHeaderTimeout
 	c.tlsHandshakeTimeout = DefaultTLSHandshakeTimeout
@@ -143,6 +201,21 @@ func (c *client) init() error {
 	if c.logPrefix != defaultLogPrefix {
 		c.log.SetPrefix(c.logPrefix)
 	}
+	if c.logger == nil {
+		c.logger = newLegacyLogger(c.log)
+	}
+	// the TLS options configure the client's own Transport
+	if c.customRoundTripper != nil && (c.clientCert != nil || c.rootCAs != nil || c.tlsConfig != nil) {
+		return fmt.Errorf("%w: TLS options cannot be combined with WithRoundTripper", ErrInvalidOptionValue)
+	}
+	// the connection faults are injected by the client's own dialer
+	if c.customRoundTripper != nil {
+		for _, f := range c.chaosFaults {
+			if f.connFault() {
+				return fmt.Errorf("%w: Chaos connection faults cannot be combined with WithRoundTripper", ErrInvalidOptionValue)
+			}
+		}
+	}
 	// if per host is unset set it to same as maxIdleConns
 	if c.maxIdleConnsPerHost < 0 {
 		c.maxIdleConnsPerHost = c.maxIdleConns
@@ -157,26 +230,76 @@ func (c *client) init() error {
 		MaxIdleConnsPerHost:   c.maxIdleConnsPerHost,
 		ResponseHeaderTimeout: c.responseHeaderTimeout,
 		TLSHandshakeTimeout:   c.tlsHandshakeTimeout,
+		TLSClientConfig:       c.tlsClientConfig(),
 		ForceAttemptHTTP2:     !c.disableHTTP2,
 	}
 	c.transport = tr
+	customRoundTripper := c.customRoundTripper != nil
 	if c.customRoundTripper == nil {
 		c.customRoundTripper = tr
 	}
-	if c.withTracing {
-		c.customRoundTripper = otelhttp.NewTransport(c.customRoundTripper)
+	if len(c.chaosFaults) > 0 {
+		timeout := c.responseHeaderTimeout
+		if timeout <= 0 {
+			timeout = DefaultResponseHeaderTimeout
+		}
+		c.chaos = newChaos(c.chaosFaults, c.chaosSeed, timeout)
+		c.customRoundTripper = c.chaos.transport(c.customRoundTripper)
+	}
+	c.customRoundTripper = &dumpTransport{
+		next:      c.customRoundTripper,
+		w:         c.debugWriter,
+		bodyLimit: c.dumpBodyLimit,
+		redact:    c.redact,
+	}
+	for _, rec := range c.harRecorders {
+		c.customRoundTripper = &harTransport{
+			next:      c.customRoundTripper,
+			rec:       rec,
+			bodyLimit: c.dumpBodyLimit,
+			redact:    c.redact,
+		}
+	}
+	if len(c.metricsRecorders) > 0 {
+		c.metrics = multiRecorder(c.metricsRecorders)
+		c.customRoundTripper = &metricsTransport{c.customRoundTripper, c.metrics}
+		for _, r := range c.metricsRecorders {
+			if sr, ok := r.(StatsRecorder); ok {
+				c.unregister = append(c.unregister, sr.RegisterStats(c.Stats))
+			}
+		}
+	}
+	if c.digest != nil {
+		c.customRoundTripper = c.digest.transport(c.customRoundTripper)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		c.customRoundTripper = c.middlewares[i](c.customRoundTripper)
+	}
+	if c.tracing != nil {
+		c.tracing.redact = c.redact
+		c.customRoundTripper = c.tracing.transport(c.customRoundTripper)
 	}
-	c.log.Printf("initialized transport: %#v\n", tr)
 
 	// create client
 	client := &http.Client{
 		Transport: c.customRoundTripper,
 	}
 	// set redirect func
-	if c.redirectFunc != nil {
-		client.CheckRedirect = c.redirectFunc
+	if c.redirectFunc != nil || len(c.hooks.onRedirect) > 0 || len(c.credentials) > 0 {
+		client.CheckRedirect = c.checkRedirect
 	}
 	c.client = client
-	c.log.Printf("initialized *http.Client: %#v\n", c.client)
+	c.logger.Debug(LogClientInitialized,
+		slog.Duration("dial_timeout", c.dialTimeout),
+		slog.Bool("disable_http2", c.disableHTTP2),
+		slog.Bool("disable_keep_alive", c.disableKeepAlive),
+		slog.Duration("idle_conn_timeout", c.idleConnTimeout),
+		slog.Duration("keep_alive_timeout", c.keepAliveTimeout),
+		slog.Int("max_idle_conns", c.maxIdleConns),
+		slog.Int("max_idle_conns_per_host", c.maxIdleConnsPerHost),
+		slog.Duration("response_header_timeout", c.responseHeaderTimeout),
+		slog.Duration("tls_handshake_timeout", c.tlsHandshakeTimeout),
+		slog.Bool("custom_round_tripper", customRoundTripper),
+		slog.Bool("tracing", c.tracing != nil))
 	return nil
 }
@@ -1,15 +1,176 @@
 package httpclient
 
 import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Option is our functional options type
 // see: https://sagikazarmark.hu/blog/functional-options-on-steroids/
 type Option func(*client) error
 
+// APIKey is configuration option to pass to client. It sets the given header
+// of every request to the Token of the Credential of p, e.g. X-API-Key, and
+// redacts it like the DefaultRedactedHeaders. The option may be given several
+// times.
+func APIKey(header string, p CredentialProvider) Option {
+	return func(c *client) error {
+		if header == "" || p == nil {
+			return ErrInvalidOptionValue
+		}
+		c.credentials = append(c.credentials, credentialHeader{provider: p, header: http.CanonicalHeaderKey(header)})
+		c.redact.addHeaders(header)
+		return nil
+	}
+}
+
+// Auth is configuration option to pass to client. It sets the Authorization
+// header of every request to a token of ts. The token is cached until it
+// expires and refreshed in the background DefaultTokenExpiryDelta before,
+// concurrent requests needing a new token share a single call to ts. A
+// request rejected with 401 Unauthorized is sent once more with a new token,
+// if its body can be sent again (see http.Request.GetBody). Requests already
+// carrying an Authorization header, e.g. set with SetHeaders, are sent as they
+// are.
+func Auth(ts TokenSource) Option {
+	return func(c *client) error {
+		if ts == nil {
+			return ErrInvalidOptionValue
+		}
+		c.auth = newTokenCache(ts)
+		return nil
+	}
+}
+
+// BasicAuth is configuration option to pass to client. It sets the
+// Authorization header of every request to the HTTP Basic credentials of the
+// given user (RFC 7617). For request specific credentials use the
+// SetBasicAuth RequestOption.
+func BasicAuth(username, password string) Option {
+	return func(c *client) error {
+		if strings.Contains(username, ":") {
+			return ErrInvalidOptionValue
+		}
+		creds := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		c.headers.Set("Authorization", "Basic "+creds)
+		return nil
+	}
+}
+
+// Chaos is configuration option to pass to client. It injects the given
+// faults, e.g. latency, connection resets, timeouts, status codes, truncated
+// bodies, slow reads or connections closed mid-stream, into the requests and
+// connections of the client, to test retries, timeouts and circuit breakers
+// locally. The option may be given several times. Connection faults cannot be
+// combined with WithRoundTripper.
+func Chaos(faults ...Fault) Option {
+	return func(c *client) error {
+		for _, f := range faults {
+			if f.Probability < 0 || f.Probability > 1 || f.TruncateBody < 0 || f.CloseAfterBytes < 0 {
+				return ErrInvalidOptionValue
+			}
+		}
+		c.chaosFaults = append(c.chaosFaults, faults...)
+		return nil
+	}
+}
+
+// ChaosSeed is configuration option to pass to client. It seeds the random
+// source deciding which faults given with Chaos are injected, making runs
+// reproducible (the current time by default).
+func ChaosSeed(seed int64) Option {
+	return func(c *client) error {
+		c.chaosSeed = seed
+		return nil
+	}
+}
+
+// ClientCertificate is configuration option to pass to client. It presents
+// the PEM encoded certificate chain and private key of the given files to
+// servers requesting a client certificate (mutual TLS). The files are read
+// again every DefaultCertificatePollInterval, or as soon as the certificate
+// expired, so that renewed short-lived certificates are used by new
+// connections without recreating the Client.
+func ClientCertificate(certFile, keyFile string) Option {
+	return func(c *client) error {
+		r, err := newCertReloader(parseX509KeyPair, certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOptionValue, err)
+		}
+		c.clientCert = r
+		return nil
+	}
+}
+
+// ClientCertificatePKCS12 is configuration option to pass to client. It is
+// like ClientCertificate, for a PKCS#12 (.p12 or .pfx) bundle of the
+// certificate chain and private key encrypted with password.
+func ClientCertificatePKCS12(file, password string) Option {
+	return func(c *client) error {
+		r, err := newCertReloader(parsePKCS12(password), file)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOptionValue, err)
+		}
+		c.clientCert = r
+		return nil
+	}
+}
+
+// Credentials is configuration option to pass to client. It sets the
+// Authorization header of every request to the Credential of p, with HTTP
+// Basic authentication if it has a Username or Password, as a Bearer token
+// otherwise. As p is asked for every request, rotated credentials are used
+// without recreating the Client, see EnvCredentials and FileCredentials.
+// Request options may replace the header.
+func Credentials(p CredentialProvider) Option {
+	return func(c *client) error {
+		if p == nil {
+			return ErrInvalidOptionValue
+		}
+		c.credentials = append(c.credentials, credentialHeader{provider: p})
+		return nil
+	}
+}
+
+// Debug is configuration option to pass to client. It writes a transcript of
+// every request going over the wire and of its response to w: request line,
+// headers and body, then status line and headers. The response body is
+// written once the ResponseHandler has read it or closed it, so streams are
+// not held back. Bodies are included up to DumpBodyLimit bytes, and the
+// redaction policy is applied. Use the DumpTo RequestOption to dump a single
+// request.
+func Debug(w io.Writer) Option {
+	return func(c *client) error {
+		c.debugWriter = w
+		return nil
+	}
+}
+
+// DigestAuth is configuration option to pass to client. It answers the
+// WWW-Authenticate: Digest challenges of servers (RFC 7616) with the
+// credentials of the given user, preferring SHA-256 over MD5. The last
+// challenge of each host is reused, counting the uses of its nonce, so that
+// the following requests are authorized without a round trip, until the
+// server sends a new challenge. A request whose body cannot be sent again
+// (see http.Request.GetBody) is not answered.
+func DigestAuth(username, password string) Option {
+	return func(c *client) error {
+		if username == "" {
+			return ErrInvalidOptionValue
+		}
+		c.digest = newDigestAuth(username, password)
+		return nil
+	}
+}
+
 // DialTimeout is configuration option to pass to client it changes how long
 // the client will wait to establish the TCP connection
 func DialTimeout(t time.Duration) Option {
@@ -37,6 +198,19 @@ func DisableKeepAlive() Option {
 	}
 }
 
+// DumpBodyLimit is configuration option to pass to client. It changes how many
+// bytes of request and response bodies are included in the transcripts
+// written by Debug and DumpTo (DefaultDumpBodyLimit by default).
+func DumpBodyLimit(n int64) Option {
+	return func(c *client) error {
+		if n < 0 {
+			return ErrInvalidOptionValue
+		}
+		c.dumpBodyLimit = n
+		return nil
+	}
+}
+
 // Headers is configuration option to pass headers to Client. It makes GET
 // requests using the provided headers. Use this for headers that are to be
 // shared among all Requests. For Request specific headers use the
@@ -83,6 +257,17 @@ func Logger(w io.Writer) Option {
 	}
 }
 
+// LogCurlOnFailure is configuration option to pass to client. For every failed
+// request, i.e. one that returned an error or a status code of 400 and above,
+// a curl command reproducing it is logged with the redaction policy applied.
+// See ToCurl for which request bodies are included.
+func LogCurlOnFailure() Option {
+	return func(c *client) error {
+		c.logCurl = true
+		return nil
+	}
+}
+
 // LogPrefix is configuration option to pass to client. It will change the
 // prefix used in Client's log output. This can be useful when one is using
 // several httpclients
@@ -93,6 +278,22 @@ func LogPrefix(p string) Option {
 	}
 }
 
+// MaxDrainBytes is configuration option to pass to client. It changes how many
+// bytes of a response body left unread by the ResponseHandler are discarded
+// before the body is closed. The body is drained of up to n bytes, for at most
+// 100ms, then closed: when it reached EOF the Transport returns the connection
+// to the idle pool, otherwise the rest of the body is left unread and the
+// connection is discarded. Zero disables draining.
+func MaxDrainBytes(n int64) Option {
+	return func(c *client) error {
+		if n < 0 {
+			return ErrInvalidOptionValue
+		}
+		c.maxDrainBytes = n
+		return nil
+	}
+}
+
 // MaxIdleConns is configuration option to pass to client. It changes the
 // maximum idle connections that the client will keep in a pool.
 func MaxIdleConns(n int) Option {
@@ -118,9 +319,107 @@ func MaxIdleConnsPerHost(n int) Option {
 	}
 }
 
+// OnError is configuration option to pass to client. The given function is
+// called when a request made with Client.Do fails without a response, before
+// the ResponseHandler. The option may be given several times.
+func OnError(f func(err error, info HookInfo)) Option {
+	return func(c *client) error {
+		if f == nil {
+			return ErrInvalidOptionValue
+		}
+		c.hooks.onError = append(c.hooks.onError, f)
+		return nil
+	}
+}
+
+// OnRedirect is configuration option to pass to client. The given function is
+// called with every redirect request the client is about to follow, after the
+// RedirectPolicy allowed it. It may modify the request, returning an error
+// stops following the redirect. The option may be given several times.
+func OnRedirect(f func(req *http.Request, via []*http.Request, info HookInfo) error) Option {
+	return func(c *client) error {
+		if f == nil {
+			return ErrInvalidOptionValue
+		}
+		c.hooks.onRedirect = append(c.hooks.onRedirect, f)
+		return nil
+	}
+}
+
+// OnRequest is configuration option to pass to client. The given function is
+// called with every request made with Client.Do once the client headers and
+// RequestOptions were applied, right before it is sent. It may modify the
+// request, returning an error aborts it. The option may be given several
+// times, the functions are called in order.
+func OnRequest(f func(req *http.Request, info HookInfo) error) Option {
+	return func(c *client) error {
+		if f == nil {
+			return ErrInvalidOptionValue
+		}
+		c.hooks.onRequest = append(c.hooks.onRequest, f)
+		return nil
+	}
+}
+
+// OnResponse is configuration option to pass to client. The given function is
+// called with the response to every request made with Client.Do once its
+// headers were received, before the ResponseHandler. It must not read the
+// body. The option may be given several times.
+func OnResponse(f func(res *http.Response, info HookInfo)) Option {
+	return func(c *client) error {
+		if f == nil {
+			return ErrInvalidOptionValue
+		}
+		c.hooks.onResponse = append(c.hooks.onResponse, f)
+		return nil
+	}
+}
+
+// OnRetry is configuration option to pass to client. The given function is
+// called before the client sends a request again, e.g. with a new token of
+// the Auth option or answering a DigestAuth challenge, or before a Middleware
+// does, see NotifyRetry, with the number of the upcoming attempt. The option
+// may be given several times.
+func OnRetry(f func(req *http.Request, info HookInfo)) Option {
+	return func(c *client) error {
+		if f == nil {
+			return ErrInvalidOptionValue
+		}
+		c.hooks.onRetry = append(c.hooks.onRetry, f)
+		return nil
+	}
+}
+
+// OnConnClose is configuration option to pass to client. The given function is
+// called with the accounting of every connection dialed by the client once it
+// is closed, e.g. to aggregate traffic per host or find short-lived
+// connections. It must not block.
+func OnConnClose(f func(ConnCloseEvent)) Option {
+	return func(c *client) error {
+		c.connCloseFunc = f
+		return nil
+	}
+}
+
+// RecordHAR is configuration option to pass to client. Every request going
+// over the wire and its response are recorded to r in the HTTP Archive format,
+// with timings and bodies up to DumpBodyLimit bytes. The redaction policy is
+// applied. A recorder may be given to several clients.
+func RecordHAR(r *HARRecorder) Option {
+	return func(c *client) error {
+		if r == nil {
+			return ErrInvalidOptionValue
+		}
+		c.harRecorders = append(c.harRecorders, r)
+		return nil
+	}
+}
+
 // RedirectPolicy is configuration option to pass to client. It changes what
 // the client does on redirects. The default behaviour is to copy the original
-// request headers and try again up to 10 times.
+// request headers and try again up to 10 times. Whatever the policy, the
+// Authorization, Cookie and Proxy-Authorization headers and those of APIKey
+// are not sent on redirects to another host.
 func RedirectPolicy(redirectFunc func(req *http.Request, via []*http.Request) error) Option {
 	return func(c *client) error {
 		c.redirectFunc = redirectFunc
@@ -128,6 +427,71 @@ func RedirectPolicy(redirectFunc func(req *http.Request, via []*http.Request) er
 	}
 }
 
+// RedactHeaders is configuration option to pass to client. The values of the
+// given headers are redacted in everything the client logs, dumps or adds to
+// spans, in addition to DefaultRedactedHeaders.
+func RedactHeaders(names ...string) Option {
+	return func(c *client) error {
+		c.redact.addHeaders(names...)
+		return nil
+	}
+}
+
+// RedactQueryParams is configuration option to pass to client. The values of
+// the given query parameters, e.g. api_key, are redacted in every URL the
+// client logs, dumps or adds to spans.
+func RedactQueryParams(names ...string) Option {
+	return func(c *client) error {
+		for _, name := range names {
+			c.redact.query[name] = true
+		}
+		return nil
+	}
+}
+
+// RedactJSONFields is configuration option to pass to client. The fields at
+// the given dot separated paths, e.g. "credentials.password", are redacted in
+// the JSON bodies the client dumps. Arrays are traversed transparently and
+// "*" matches any field name, so "*.token" redacts the token field of the
+// objects nested one level deep.
+func RedactJSONFields(paths ...string) Option {
+	return func(c *client) error {
+		for _, path := range paths {
+			if path == "" {
+				return ErrInvalidOptionValue
+			}
+			c.redact.jsonFields = append(c.redact.jsonFields, strings.Split(path, "."))
+		}
+		return nil
+	}
+}
+
+// UnredactHeaders is configuration option to pass to client. The values of
+// the given headers, redacted by default or by the options given before, e.g.
+// Cookie, are no longer redacted. Beware that they may then leak to logs.
+func UnredactHeaders(names ...string) Option {
+	return func(c *client) error {
+		for _, name := range names {
+			delete(c.redact.headers, http.CanonicalHeaderKey(name))
+		}
+		return nil
+	}
+}
+
+// TLSConfig is configuration option to pass to client. It sets the TLS
+// configuration of the connections, e.g. the minimum version or cipher
+// suites. The configuration is cloned, RootCAs, ClientCertificate and
+// ClientCertificatePKCS12 are applied to the clone.
+func TLSConfig(cfg *tls.Config) Option {
+	return func(c *client) error {
+		if cfg == nil {
+			return ErrInvalidOptionValue
+		}
+		c.tlsConfig = cfg.Clone()
+		return nil
+	}
+}
+
 // TLSHandshakeTimeout is a configuration option to pass to client. It limits
 // the time spent performing the TLS handshake.
 func TLSHandshakeTimeout(t time.Duration) Option {
@@ -137,6 +501,20 @@ func TLSHandshakeTimeout(t time.Duration) Option {
 	}
 }
 
+// RootCAs is configuration option to pass to client. It verifies the
+// certificates of servers with the CA certificates of the given PEM encoded
+// files instead of those of the system, e.g. those of an internal CA.
+func RootCAs(files ...string) Option {
+	return func(c *client) error {
+		pool, err := loadCertPool(files)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOptionValue, err)
+		}
+		c.rootCAs = pool
+		return nil
+	}
+}
+
 // ResponseHeaderTimeout is a configuration option to pass to client. It limits
 // the time spent reading the headers of the response.
 func ResponseHeaderTimeout(t time.Duration) Option {
@@ -154,8 +532,42 @@ func (r RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return r(req)
 }
 
+// Middleware wraps the RoundTripper of the client, see Use
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Use is configuration option to pass to client. It adds middleware to the
+// transport stack of the client, e.g. for auth, caching or retries, while
+// keeping the tuned Transport underneath. From the outside in the stack is:
+//
+//	tracing (WithTracing)
+//	middleware, the first one given outermost
+//	digest authentication (DigestAuth)
+//	metrics (WithMetrics, WithMetricsRecorder)
+//	HAR recording (RecordHAR)
+//	transcripts (Debug, DumpTo)
+//	fault injection (Chaos)
+//	the Transport, or the RoundTripper given with WithRoundTripper
+//
+// so a request goes through the middleware in the order it was given, and
+// every request a middleware sends, e.g. each attempt of a retry, is measured,
+// recorded and dumped on its own. The option may be given several times.
+func Use(mw ...Middleware) Option {
+	return func(c *client) error {
+		for _, m := range mw {
+			if m == nil {
+				return ErrInvalidOptionValue
+			}
+		}
+		c.middlewares = append(c.middlewares, mw...)
+		return nil
+	}
+}
+
 // WithRoundTripper is configuration option to pass to client. This will change
-// the http.RoundTripper that the client will use.
+// the http.RoundTripper that the client will use. To add behaviour on top of
+// the client's own Transport see Use instead. It cannot be combined with the
+// TLS options, ClientCertificate, ClientCertificatePKCS12, RootCAs and
+// TLSConfig, which configure the client's own Transport.
 //
 // NOTE: the usage of this renders the use of httpclient pointless, because if
 //       you are managing your own transports, you might as well use net/http
@@ -169,11 +581,67 @@ func WithRoundTripper(rt http.RoundTripper) Option {
 	}
 }
 
+// WithMetrics is configuration option to pass to client. It records the
+// OpenTelemetry HTTP client semantic convention metrics
+// (http.client.request.duration, http.client.request.body.size,
+// http.client.response.body.size and http.client.open_connections) as well as
+// httpclient.dial.duration, httpclient.tls.handshake.duration and
+// httpclient.connection.closed with the given MeterProvider. Use it alongside
+// WithTracing to get both spans and metrics.
+func WithMetrics(mp metric.MeterProvider) Option {
+	return func(c *client) error {
+		if mp == nil {
+			return ErrInvalidOptionValue
+		}
+		m, err := newOtelMetrics(mp)
+		if err != nil {
+			return err
+		}
+		c.metricsRecorders = append(c.metricsRecorders, m)
+		return nil
+	}
+}
+
+// WithMetricsRecorder is configuration option to pass to client. It passes the
+// measurements of every request, dial and TLS handshake made by the client to
+// the given MetricsRecorder. The option may be given several times to record
+// to several backends.
+func WithMetricsRecorder(r MetricsRecorder) Option {
+	return func(c *client) error {
+		if r == nil {
+			return ErrInvalidOptionValue
+		}
+		c.metricsRecorders = append(c.metricsRecorders, r)
+		return nil
+	}
+}
+
+// WithSlogger is configuration option to pass to client. It makes the client
+// emit structured, levelled events (see the Log* message and LogKey* attribute
+// constants) to the given logger: request start and end, dials, connections
+// acquired and closed, redirects and retries. The Logger and LogPrefix options
+// are ignored when a slog.Logger is given.
+func WithSlogger(l *slog.Logger) Option {
+	return func(c *client) error {
+		if l == nil {
+			return ErrInvalidOptionValue
+		}
+		c.logger = l
+		return nil
+	}
+}
+
 // WithTracing enables instrumentation of the Client's HTTP Transport with
-// OpenTelemetry.
-func WithTracing() Option {
+// OpenTelemetry. Without any TracingOptions the global TracerProvider and
+// TextMapPropagator are used.
+func WithTracing(opts ...TracingOption) Option {
 	return func(c *client) error {
-		c.withTracing = true
+		if c.tracing == nil {
+			c.tracing = new(tracingConfig)
+		}
+		for _, opt := range opts {
+			opt(c.tracing)
+		}
 		return nil
 	}
 }
@@ -39,6 +39,7 @@ func TestClient(t *testing.T) {
 		errstr string
 	}{
 		{badConfigOption(), "badd"},
+		{MaxDrainBytes(-1), ErrInvalidOptionValue.Error()},
 		{MaxIdleConns(-1), ErrInvalidOptionValue.Error()},
 		{MaxIdleConnsPerHost(-2), ErrInvalidOptionValue.Error()},
 	}
@@ -0,0 +1,240 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCredentialPollInterval is how often the files of FileCredentials are
+// read again by default
+const DefaultCredentialPollInterval = 10 * time.Second
+
+// ErrEmptyCredential is returned by requests whose CredentialProvider returns
+// an empty Credential
+var ErrEmptyCredential = errors.New("httpclient: empty credential")
+
+// Credential is a secret authenticating requests, a basic-auth pair or a
+// token such as an API key
+type Credential struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// CredentialProvider returns the current Credential. It is called for every
+// request, so that rotated credentials are used without recreating the
+// Client.
+type CredentialProvider interface {
+	Credential(ctx context.Context) (Credential, error)
+}
+
+// CredentialProviderFunc is like http.HandlerFunc, but for the
+// CredentialProvider interface
+type CredentialProviderFunc func(ctx context.Context) (Credential, error)
+
+// Credential satisfies the CredentialProvider interface
+func (f CredentialProviderFunc) Credential(ctx context.Context) (Credential, error) {
+	return f(ctx)
+}
+
+// EnvCredentialConfig names the environment variables of a Credential, empty
+// names are skipped
+type EnvCredentialConfig struct {
+	UsernameVar string
+	PasswordVar string
+	TokenVar    string
+}
+
+// EnvCredentials returns a CredentialProvider reading the Credential from
+// environment variables on every call
+func EnvCredentials(cfg EnvCredentialConfig) CredentialProvider {
+	return CredentialProviderFunc(func(ctx context.Context) (Credential, error) {
+		var cred Credential
+		for _, v := range []struct {
+			name  string
+			value *string
+		}{
+			{cfg.UsernameVar, &cred.Username},
+			{cfg.PasswordVar, &cred.Password},
+			{cfg.TokenVar, &cred.Token},
+		} {
+			if v.name != "" {
+				*v.value = os.Getenv(v.name)
+			}
+		}
+		return cred, nil
+	})
+}
+
+// FileCredentialConfig names the files of a Credential, e.g. the keys of a
+// Kubernetes secret mounted as a volume. Empty names are skipped.
+type FileCredentialConfig struct {
+	UsernameFile string
+	PasswordFile string
+	TokenFile    string
+	// PollInterval is how often the files are read again,
+	// DefaultCredentialPollInterval if zero
+	PollInterval time.Duration
+	// MaxStale is how long the last Credential is used while the files
+	// cannot be read, three poll intervals if zero
+	MaxStale time.Duration
+}
+
+// fileCredentials is a CredentialProvider reading its files again once per
+// poll interval, when it is asked for the Credential
+type fileCredentials struct {
+	cfg      FileCredentialConfig
+	readFile func(name string) ([]byte, error)
+
+	mu      sync.Mutex // guards below
+	cred    Credential
+	checked time.Time
+	err     error     // of the last read
+	failing time.Time // since the reads fail
+}
+
+// FileCredentials returns a CredentialProvider reading the Credential from
+// files, whose content is trimmed of its trailing new line. The files are not
+// watched: they are read again when a request needs the Credential, at most
+// once per poll interval, so that a rotated secret is used once written to
+// disk. The first file is read again after the others, and all of them again
+// if it changed, so that a username and password of different rotations are
+// not paired. The last Credential read is kept while the files cannot be
+// read, e.g. in the middle of a rotation, until they failed for MaxStale;
+// requests then fail with the read error. It fails if the files cannot be
+// read initially.
+func FileCredentials(cfg FileCredentialConfig) (CredentialProvider, error) {
+	if cfg.PollInterval < 0 || cfg.MaxStale < 0 {
+		return nil, ErrInvalidOptionValue
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultCredentialPollInterval
+	}
+	if cfg.MaxStale == 0 {
+		cfg.MaxStale = 3 * cfg.PollInterval
+	}
+	fc := &fileCredentials{cfg: cfg, readFile: os.ReadFile}
+	cred, err := fc.read()
+	if err != nil {
+		return nil, err
+	}
+	fc.cred, fc.checked = cred, time.Now()
+	return fc, nil
+}
+
+// Credential satisfies the CredentialProvider interface
+func (fc *fileCredentials) Credential(ctx context.Context) (Credential, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if time.Since(fc.checked) >= fc.cfg.PollInterval {
+		fc.checked = time.Now()
+		cred, err := fc.read()
+		switch {
+		case err == nil:
+			fc.cred, fc.err = cred, nil
+		case fc.err == nil:
+			fc.err, fc.failing = err, fc.checked
+		default:
+			fc.err = err
+		}
+	}
+	if fc.err != nil && time.Since(fc.failing) >= fc.cfg.MaxStale {
+		return Credential{}, fc.err
+	}
+	return fc.cred, nil
+}
+
+// maxCredentialReads is how many times the files of FileCredentials are read
+// while they keep changing
+const maxCredentialReads = 3
+
+// read reads the Credential from the files. The first file is read again once
+// the others were, and all of them again if it changed, so that the username
+// and password of different rotations are not paired.
+func (fc *fileCredentials) read() (Credential, error) {
+	for i := 0; i < maxCredentialReads; i++ {
+		var cred Credential
+		var first, value string
+		files := 0
+		for _, f := range []struct {
+			name  string
+			value *string
+		}{
+			{fc.cfg.UsernameFile, &cred.Username},
+			{fc.cfg.PasswordFile, &cred.Password},
+			{fc.cfg.TokenFile, &cred.Token},
+		} {
+			if f.name == "" {
+				continue
+			}
+			v, err := fc.readValue(f.name)
+			if err != nil {
+				return Credential{}, err
+			}
+			*f.value = v
+			if files == 0 {
+				first, value = f.name, v
+			}
+			files++
+		}
+		if files < 2 {
+			return cred, nil
+		}
+		again, err := fc.readValue(first)
+		if err != nil {
+			return Credential{}, err
+		}
+		if again == value {
+			return cred, nil
+		}
+	}
+	return Credential{}, errors.New("reading credential: files changed while read")
+}
+
+// readValue reads the file name, trimmed of its trailing new line
+func (fc *fileCredentials) readValue(name string) (string, error) {
+	b, err := fc.readFile(name)
+	if err != nil {
+		return "", fmt.Errorf("reading credential: %w", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// credentialHeader sets a header of the requests to a Credential
+type credentialHeader struct {
+	provider CredentialProvider
+	// header set to the Token, the Authorization header if empty
+	header string
+}
+
+// setCredentials sets the headers of req to the credentials of the client
+func (c *client) setCredentials(req *http.Request) error {
+	for _, ch := range c.credentials {
+		cred, err := ch.provider.Credential(req.Context())
+		if err != nil {
+			return fmt.Errorf("fetching credential: %w", err)
+		}
+		switch {
+		case ch.header != "":
+			if cred.Token == "" {
+				return ErrEmptyCredential
+			}
+			req.Header.Set(ch.header, cred.Token)
+		case cred.Username != "" || cred.Password != "":
+			creds := base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password))
+			req.Header.Set("Authorization", "Basic "+creds)
+		case cred.Token != "":
+			req.Header.Set("Authorization", Token{AccessToken: cred.Token}.header())
+		default:
+			return ErrEmptyCredential
+		}
+	}
+	return nil
+}
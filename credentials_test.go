@@ -0,0 +1,178 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("TEST_USERNAME", "user")
+	t.Setenv("TEST_PASSWORD", "pass")
+	p := EnvCredentials(EnvCredentialConfig{UsernameVar: "TEST_USERNAME", PasswordVar: "TEST_PASSWORD"})
+	if cred, _ := p.Credential(context.Background()); cred != (Credential{Username: "user", Password: "pass"}) {
+		t.Errorf("unexpected credential %+v", cred)
+	}
+	t.Setenv("TEST_PASSWORD", "rotated")
+	if cred, _ := p.Credential(context.Background()); cred.Password != "rotated" {
+		t.Errorf("expected the variable to be read again, got %+v", cred)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	token := filepath.Join(dir, "token")
+	write := func(content string) {
+		if err := os.WriteFile(token, []byte(content), 0600); err != nil {
+			t.Fatalf("trouble when writing the token: %v", err)
+		}
+	}
+	if _, err := FileCredentials(FileCredentialConfig{TokenFile: token}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	write("token-1\n")
+	p, err := FileCredentials(FileCredentialConfig{TokenFile: token, PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("trouble when creating the provider: %v", err)
+	}
+	ctx := context.Background()
+	if cred, _ := p.Credential(ctx); cred.Token != "token-1" {
+		t.Errorf("expected token-1, got %q", cred.Token)
+	}
+
+	write("token-2\n")
+	if cred, _ := p.Credential(ctx); cred.Token != "token-1" {
+		t.Errorf("expected the file not to be read again before the poll interval, got %q", cred.Token)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if cred, _ := p.Credential(ctx); cred.Token != "token-2" {
+		t.Errorf("expected the rotated token-2, got %q", cred.Token)
+	}
+
+	// the last credential is kept in the middle of a rotation
+	os.Remove(token)
+	time.Sleep(20 * time.Millisecond)
+	if cred, err := p.Credential(ctx); err != nil || cred.Token != "token-2" {
+		t.Errorf("expected the last token-2, got %q, %v", cred.Token, err)
+	}
+	// until the files failed for too long
+	time.Sleep(40 * time.Millisecond)
+	if _, err := p.Credential(ctx); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the read error once stale, got %v", err)
+	}
+	write("token-3\n")
+	time.Sleep(20 * time.Millisecond)
+	if cred, err := p.Credential(ctx); err != nil || cred.Token != "token-3" {
+		t.Errorf("expected the recovered token-3, got %q, %v", cred.Token, err)
+	}
+	if _, err := FileCredentials(FileCredentialConfig{TokenFile: token, MaxStale: -1}); err != ErrInvalidOptionValue {
+		t.Errorf("expected ErrInvalidOptionValue, got %v", err)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Authorization", r.Header.Get("Authorization"))
+		w.Header().Set("X-Received-Key", r.Header.Get("X-Api-Key"))
+	}))
+	defer s.Close()
+
+	var cred Credential
+	p := CredentialProviderFunc(func(ctx context.Context) (Credential, error) {
+		return cred, nil
+	})
+	c, err := New(Credentials(p), APIKey("X-API-Key", CredentialProviderFunc(func(ctx context.Context) (Credential, error) {
+		return Credential{Token: "key"}, nil
+	})))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	get := func(opts ...RequestOption) (http.Header, error) {
+		var header http.Header
+		rh := func(ctx context.Context, res *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			header = res.Header
+			return nil
+		}
+		err := c.Get(context.Background(), rh, s.URL, opts...)
+		return header, err
+	}
+
+	for _, tc := range []struct {
+		cred     Credential
+		expected string
+	}{
+		{Credential{Username: "Aladdin", Password: "open sesame"}, "Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ=="},
+		{Credential{Token: "t0ken"}, "Bearer t0ken"},
+	} {
+		cred = tc.cred
+		header, err := get()
+		if err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+		if header.Get("X-Authorization") != tc.expected || header.Get("X-Received-Key") != "key" {
+			t.Errorf("expected %s, got %v", tc.expected, header)
+		}
+	}
+	if header, _ := get(SetBasicAuth("Jasmine", "carpet")); header.Get("X-Authorization") != "Basic SmFzbWluZTpjYXJwZXQ=" {
+		t.Errorf("expected the request option to replace the credential, got %s", header.Get("X-Authorization"))
+	}
+
+	if h := c.(*client).redact.Header(http.Header{"X-Api-Key": {"key"}}); h.Get("X-Api-Key") != RedactedValue {
+		t.Errorf("expected the API key to be redacted, got %v", h)
+	}
+
+	cred = Credential{}
+	if _, err := get(); !errors.Is(err, ErrEmptyCredential) {
+		t.Errorf("expected ErrEmptyCredential, got %v", err)
+	}
+	for _, opt := range []Option{Credentials(nil), APIKey("", p)} {
+		if _, err := New(opt); err != ErrInvalidOptionValue {
+			t.Errorf("expected ErrInvalidOptionValue, got %v", err)
+		}
+	}
+}
+
+func TestFileCredentialsRotation(t *testing.T) {
+	files := map[string]string{"username": "user-1", "password": "pass-1"}
+	rotations, rotated := 1, 1
+	fc := &fileCredentials{
+		cfg: FileCredentialConfig{UsernameFile: "username", PasswordFile: "password", PollInterval: time.Nanosecond, MaxStale: time.Hour},
+		readFile: func(name string) ([]byte, error) {
+			value := files[name]
+			// the secret is rotated right after the password was read
+			if name == "password" && rotations > 0 {
+				rotations--
+				rotated++
+				n := strconv.Itoa(rotated)
+				files["username"], files["password"] = "user-"+n, "pass-"+n
+			}
+			return []byte(value), nil
+		},
+	}
+
+	// the files are read again once the username changed
+	cred, err := fc.read()
+	if err != nil || cred != (Credential{Username: "user-2", Password: "pass-2"}) {
+		t.Errorf("expected the pair of the last rotation, got %+v, %v", cred, err)
+	}
+
+	// the previous pair is kept while the files keep changing
+	fc.cred = cred
+	rotations = maxCredentialReads
+	if cred, err := fc.Credential(context.Background()); err != nil || cred != (Credential{Username: "user-2", Password: "pass-2"}) {
+		t.Errorf("expected the previous pair, got %+v, %v", cred, err)
+	}
+	if fc.err == nil {
+		t.Error("expected the changing files to fail the read")
+	}
+}
@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ToCurl returns a curl command reproducing req: method, URL, headers and the
+// body, if it can be read again through req.GetBody. Requests created by
+// http.NewRequest with a *bytes.Buffer, *bytes.Reader or *strings.Reader
+// body are replayable. Nothing is redacted, see the LogCurlOnFailure option
+// for a redacted version in the logs.
+func ToCurl(req *http.Request) (string, error) {
+	return toCurl(req, nil)
+}
+
+// toCurl returns the curl command of req, redacted by r if it is not nil
+func toCurl(req *http.Request, r *redactor) (string, error) {
+	var b strings.Builder
+	b.WriteString("curl")
+	switch req.Method {
+	case "", http.MethodGet:
+	case http.MethodHead:
+		// -X HEAD would wait for a body that never comes
+		b.WriteString(" --head")
+	default:
+		b.WriteString(" -X " + req.Method)
+	}
+	u := req.URL.String()
+	header := req.Header
+	if r != nil {
+		u = r.URL(req.URL)
+		header = r.Header(header)
+	}
+	b.WriteString(" " + shellQuote(u))
+	if req.Host != "" && req.Host != req.URL.Host {
+		b.WriteString(" -H " + shellQuote("Host: "+req.Host))
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			b.WriteString(" -H " + shellQuote(k+": "+v))
+		}
+	}
+	if req.GetBody != nil && req.ContentLength != 0 {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+		if r != nil && strings.Contains(header.Get("Content-Type"), "json") {
+			data = r.JSON(data)
+		}
+		b.WriteString(" --data-raw " + shellQuote(string(data)))
+	}
+	return b.String(), nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
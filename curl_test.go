@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToCurl(t *testing.T) {
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com/users?page=2", strings.NewReader(`{"name":"O'Brien"}`))
+	post.Header.Set("Content-Type", "application/json")
+	post.Header.Set("Accept", "application/json")
+
+	get, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	get.Host = "api.example.com"
+
+	stream, _ := http.NewRequest(http.MethodPut, "https://example.com/upload", nil)
+	stream.Body = http.NoBody
+
+	head, _ := http.NewRequest(http.MethodHead, "https://example.com/", nil)
+
+	tests := []struct {
+		req      *http.Request
+		expected string
+	}{
+		{post, `curl -X POST 'https://example.com/users?page=2' -H 'Accept: application/json' -H 'Content-Type: application/json' --data-raw '{"name":"O'\''Brien"}'`},
+		{get, `curl 'https://example.com/' -H 'Host: api.example.com'`},
+		{stream, `curl -X PUT 'https://example.com/upload'`},
+		{head, `curl --head 'https://example.com/'`},
+	}
+	for _, tc := range tests {
+		cmd, err := ToCurl(tc.req)
+		if err != nil {
+			t.Fatalf("trouble when generating curl command: %v", err)
+		}
+		if cmd != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, cmd)
+		}
+	}
+}
+
+func TestLogCurlOnFailure(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer s.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	c, err := New(
+		WithSlogger(logger),
+		LogCurlOnFailure(),
+		Headers(http.Header{"Authorization": {"Bearer secret"}}),
+		RedactQueryParams("api_key"),
+		RedactJSONFields("password"),
+	)
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	body := `{"user":"bob","password":"hunter2"}`
+	for _, path := range []string{"/ok", "/fail"} {
+		err := c.Post(context.Background(), NoopResponseHandler, s.URL+path+"?api_key=k", strings.NewReader(body),
+			SetHeaders(http.Header{"Content-Type": {"application/json"}}))
+		if err != nil {
+			t.Fatalf("trouble when making POST request: %v", err)
+		}
+	}
+
+	var cmds []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if ev[slog.MessageKey] == LogCurl {
+			cmds = append(cmds, ev[LogKeyCurl].(string))
+		}
+	}
+	if len(cmds) != 1 {
+		t.Fatalf("expected 1 curl command, got %d: %v", len(cmds), cmds)
+	}
+	expected := `curl -X POST '` + s.URL + `/fail?api_key=REDACTED' -H 'Authorization: REDACTED' -H 'Content-Type: application/json' --data-raw '{"password":"REDACTED","user":"bob"}'`
+	if cmds[0] != expected {
+		t.Errorf("expected %s, got %s", expected, cmds[0])
+	}
+}
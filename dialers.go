@@ -2,16 +2,56 @@ package httpclient
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
 	"net"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// ConnCloseReason describes why a connection dialed by the Client was closed
+type ConnCloseReason string
+
+const (
+	// CloseReasonClient - the connection was idle when Client.Close was called
+	CloseReasonClient ConnCloseReason = "client closed"
+	// CloseReasonRemote - the remote end closed the connection
+	CloseReasonRemote ConnCloseReason = "remote closed"
+	// CloseReasonReadError - reading from the connection failed
+	CloseReasonReadError ConnCloseReason = "read error"
+	// CloseReasonWriteError - writing to the connection failed
+	CloseReasonWriteError ConnCloseReason = "write error"
+	// CloseReasonTransport - the Transport closed the connection, e.g. because
+	// it was idle for longer than IdleConnTimeout or the idle pool was full
+	CloseReasonTransport ConnCloseReason = "transport closed"
+)
+
+// ConnCloseEvent holds the accounting of a single connection dialed by the
+// Client. It is passed to the OnConnClose callback once the connection is
+// closed.
+type ConnCloseEvent struct {
+	ConnID       int64
+	Network      string
+	Addr         string // the dialed address
+	RemoteAddr   string
+	LocalAddr    string
+	BytesRead    int64
+	BytesWritten int64
+	Requests     int64 // requests served through Client.Do
+	Lifetime     time.Duration
+	Reason       ConnCloseReason
+}
+
 func (c *client) nextConnID() int64 {
 	return atomic.AddInt64(&c.currentConnID, 1)
 }
 
 func (c *client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
 	connID := c.nextConnID()
+	stats := c.connStats.host(addr)
 	dialerThing := &net.Dialer{
 		Timeout: c.dialTimeout,
 	}
@@ -19,31 +59,200 @@ func (c *client) dialContext(ctx context.Context, network, addr string) (net.Con
 		dialerThing.KeepAlive = c.keepAliveTimeout
 	}
 	dc := dialerThing.DialContext
-	c.log.Printf("Dialing conn %d to %s %s", connID, network, addr)
+	if c.chaos != nil {
+		dc = c.chaos.dialer(dc)
+	}
+	c.logger.Debug(LogDial,
+		slog.Int64(LogKeyConnID, connID),
+		slog.String(LogKeyNetwork, network),
+		slog.String(LogKeyAddr, addr))
+	atomic.AddInt64(&stats.dials, 1)
+	ctx, span := c.tracing.startDialSpan(ctx, connID, network, addr)
+	start := time.Now()
 	conn, err := dc(ctx, network, addr)
+	if span != nil {
+		endSpan(span, err)
+	}
+	if c.metrics != nil {
+		c.metrics.Dialed(DialMetrics{
+			Network:  network,
+			Addr:     addr,
+			ConnID:   connID,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
 	if err != nil {
-		c.log.Printf(
-			"Dialing conn %d to %s %s failed with %s",
-			connID, network, addr, err.Error())
+		atomic.AddInt64(&stats.dialFailures, 1)
+		c.logger.Warn(LogDialFailed,
+			slog.Int64(LogKeyConnID, connID),
+			slog.String(LogKeyNetwork, network),
+			slog.String(LogKeyAddr, addr),
+			slog.Duration(LogKeyDuration, time.Since(start)),
+			slog.String(LogKeyError, err.Error()))
 		return conn, err
 	}
-	onClose := func() {
-		c.log.Printf("Closing conn %d to %s %s", connID, network, addr)
+	atomic.AddInt64(&stats.open, 1)
+	cw := newConn(conn, connID, stats, c.onConnClosed)
+	cw.network, cw.addr = network, addr
+	return cw, err
+}
+
+// onConnClosed logs the accounting of a closed connection and passes it on to
+// the OnConnClose callback
+func (c *client) onConnClosed(cw *connWrapper) {
+	ev := cw.closeEvent()
+	if ev.Reason == CloseReasonTransport && atomic.LoadInt32(&c.closing) == 1 {
+		ev.Reason = CloseReasonClient
+	}
+	c.logger.Debug(LogConnClosed,
+		slog.Int64(LogKeyConnID, ev.ConnID),
+		slog.String(LogKeyNetwork, ev.Network),
+		slog.String(LogKeyAddr, ev.Addr),
+		slog.String(LogKeyReason, string(ev.Reason)),
+		slog.Duration(LogKeyLifetime, ev.Lifetime),
+		slog.Int64(LogKeyRequests, ev.Requests),
+		slog.Int64(LogKeyBytesRead, ev.BytesRead),
+		slog.Int64(LogKeyBytesWritten, ev.BytesWritten))
+	if c.connCloseFunc != nil {
+		c.connCloseFunc(ev)
 	}
-	return newConn(conn, onClose), err
 }
 
 // below the connection wrapper to keep track of what is happening on TCP level
-func newConn(conn net.Conn, onClose func()) net.Conn {
-	return &connWrapper{conn, onClose}
+func newConn(conn net.Conn, id int64, stats *hostStats, onClose func(*connWrapper)) *connWrapper {
+	return &connWrapper{
+		Conn:    conn,
+		id:      id,
+		opened:  time.Now(),
+		stats:   stats,
+		onClose: onClose,
+	}
 }
 
 type connWrapper struct {
 	net.Conn
-	onClose func()
+	id           int64
+	network      string
+	addr         string
+	opened       time.Time
+	stats        *hostStats
+	onClose      func(*connWrapper)
+	bytesRead    int64
+	bytesWritten int64
+
+	mu       sync.Mutex // guards below
+	inflight int
+	requests int64
+	closed   bool
+	reason   ConnCloseReason
+}
+
+func (c *connWrapper) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.bytesRead, int64(n))
+	atomic.AddInt64(&c.stats.bytesRead, int64(n))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			c.setReason(CloseReasonRemote)
+		} else {
+			c.setReason(CloseReasonReadError)
+		}
+	}
+	return n, err
+}
+
+func (c *connWrapper) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.bytesWritten, int64(n))
+	atomic.AddInt64(&c.stats.bytesWritten, int64(n))
+	if err != nil {
+		c.setReason(CloseReasonWriteError)
+	}
+	return n, err
 }
 
 func (c *connWrapper) Close() error {
-	c.onClose()
+	c.mu.Lock()
+	first := !c.closed
+	if first {
+		c.closed = true
+		if c.inflight > 0 {
+			atomic.AddInt64(&c.stats.active, -1)
+		}
+		atomic.AddInt64(&c.stats.open, -1)
+		atomic.AddInt64(&c.stats.closed, 1)
+	}
+	c.mu.Unlock()
+	if first {
+		c.onClose(c)
+	}
 	return c.Conn.Close()
 }
+
+// setReason records the first error seen on an open connection as the reason
+// it is about to be closed
+func (c *connWrapper) setReason(r ConnCloseReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed && c.reason == "" {
+		c.reason = r
+	}
+}
+
+// closeEvent returns the accounting of the connection
+func (c *connWrapper) closeEvent() ConnCloseEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ev := ConnCloseEvent{
+		ConnID:       c.id,
+		Network:      c.network,
+		Addr:         c.addr,
+		BytesRead:    atomic.LoadInt64(&c.bytesRead),
+		BytesWritten: atomic.LoadInt64(&c.bytesWritten),
+		Requests:     c.requests,
+		Lifetime:     time.Since(c.opened),
+		Reason:       c.reason,
+	}
+	if ev.Reason == "" {
+		ev.Reason = CloseReasonTransport
+	}
+	if addr := c.RemoteAddr(); addr != nil {
+		ev.RemoteAddr = addr.String()
+	}
+	if addr := c.LocalAddr(); addr != nil {
+		ev.LocalAddr = addr.String()
+	}
+	return ev
+}
+
+// acquire marks the connection as serving one more request
+func (c *connWrapper) acquire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight++
+	c.requests++
+	if c.inflight == 1 && !c.closed {
+		atomic.AddInt64(&c.stats.active, 1)
+	}
+}
+
+// release marks a request served by the connection as done
+func (c *connWrapper) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if c.inflight == 0 && !c.closed {
+		atomic.AddInt64(&c.stats.active, -1)
+	}
+}
+
+// unwrapConn returns the *connWrapper created by dialContext for a connection
+// handed out by the Transport, which may have wrapped it for TLS
+func unwrapConn(conn net.Conn) (*connWrapper, bool) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	cw, ok := conn.(*connWrapper)
+	return cw, ok
+}
@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOnConnClose(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer s.Close()
+
+	events := make(chan ConnCloseEvent, 1)
+	c, err := New(OnConnClose(func(ev ConnCloseEvent) { events <- ev }))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		close  func()
+		reason ConnCloseReason
+	}{
+		{"client Close", c.Close, CloseReasonClient},
+		{"server hangs up", s.CloseClientConnections, CloseReasonRemote},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				if err := c.Get(context.Background(), NoopResponseHandler, s.URL); err != nil {
+					t.Fatalf("trouble when making GET request: %v", err)
+				}
+			}
+			tc.close()
+
+			var ev ConnCloseEvent
+			select {
+			case ev = <-events:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the conn to be closed")
+			}
+			if ev.Reason != tc.reason {
+				t.Errorf("expected close reason %q, got %q", tc.reason, ev.Reason)
+			}
+			if ev.Requests != 2 {
+				t.Errorf("expected 2 requests served, got %d", ev.Requests)
+			}
+			if ev.ConnID == 0 || ev.BytesRead == 0 || ev.BytesWritten == 0 || ev.Lifetime <= 0 {
+				t.Errorf("expected conn accounting, got %+v", ev)
+			}
+			if ev.RemoteAddr != s.Listener.Addr().String() || ev.LocalAddr == "" {
+				t.Errorf("unexpected addresses in %+v", ev)
+			}
+		})
+	}
+}
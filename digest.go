@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestChallenge is a WWW-Authenticate: Digest challenge (RFC 7616)
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string // as given, MD5 if not given
+	qop       string // "auth" or empty for RFC 2069 compatibility
+	userhash  bool
+	stale     bool
+}
+
+// digestAlgorithms are the supported algorithms, most preferred first
+var digestAlgorithms = []string{"SHA-256", "SHA-256-SESS", "MD5", "MD5-SESS"}
+
+// parseDigestChallenge returns the preferred Digest challenge of the given
+// WWW-Authenticate header values
+func parseDigestChallenge(values []string) (*digestChallenge, error) {
+	var best *digestChallenge
+	rank := len(digestAlgorithms)
+	for _, v := range values {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		p := parseAuthParams(params)
+		ch := &digestChallenge{
+			realm:     p["realm"],
+			nonce:     p["nonce"],
+			opaque:    p["opaque"],
+			algorithm: p["algorithm"],
+			userhash:  strings.EqualFold(p["userhash"], "true"),
+			stale:     strings.EqualFold(p["stale"], "true"),
+		}
+		if ch.algorithm == "" {
+			ch.algorithm = "MD5"
+		}
+		if qop, ok := p["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					ch.qop = "auth"
+				}
+			}
+			if ch.qop == "" {
+				// only auth-int is offered
+				continue
+			}
+		}
+		for i, alg := range digestAlgorithms {
+			if strings.EqualFold(alg, ch.algorithm) && i < rank && ch.nonce != "" {
+				best, rank = ch, i
+			}
+		}
+	}
+	if best == nil {
+		return nil, errors.New("httpclient: no supported Digest challenge")
+	}
+	return best, nil
+}
+
+// parseAuthParams parses the comma separated auth-params of a challenge,
+// whose values are tokens or quoted strings
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[min(i+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		params[key] = value.String()
+	}
+}
+
+// digestAuth answers the Digest challenges of the hosts it talks to. The last
+// challenge of each host is reused for its following requests, counting the
+// uses of the nonce, so that they are authorized up front.
+type digestAuth struct {
+	username string
+	password string
+
+	mu    sync.Mutex // guards below
+	hosts map[string]*digestNonce
+}
+
+// digestNonce is a challenge and how many requests used its nonce
+type digestNonce struct {
+	challenge *digestChallenge
+	count     uint32
+}
+
+func newDigestAuth(username, password string) *digestAuth {
+	return &digestAuth{username: username, password: password, hosts: make(map[string]*digestNonce)}
+}
+
+// transport returns next answering Digest challenges
+func (d *digestAuth) transport(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent := req
+		var sentNonce string
+		if auth, nonce, ok := d.authorization(req); ok {
+			sent = req.Clone(req.Context())
+			sent.Header.Set("Authorization", auth)
+			sentNonce = nonce
+		}
+		res, err := next.RoundTrip(sent)
+		if err != nil || res.StatusCode != http.StatusUnauthorized {
+			return res, err
+		}
+		ch, err := parseDigestChallenge(res.Header.Values("WWW-Authenticate"))
+		if err != nil {
+			// not a Digest challenge
+			return res, nil
+		}
+		// the new challenge is kept even if the request is not sent again, so
+		// that the following requests do not replay a dead nonce
+		d.mu.Lock()
+		d.hosts[req.URL.Host] = &digestNonce{challenge: ch}
+		d.mu.Unlock()
+		if sent != req && ch.nonce == sentNonce {
+			// the credentials were rejected
+			return res, nil
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return res, nil
+		}
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return res, nil
+			}
+			retry.Body = body
+		}
+		auth, _, _ := d.authorization(retry)
+		retry.Header.Set("Authorization", auth)
+		io.CopyN(io.Discard, res.Body, DefaultMaxDrainBytes)
+		res.Body.Close()
+		NotifyRetry(retry)
+		return next.RoundTrip(retry)
+	})
+}
+
+// authorization returns the Authorization header of req for the challenge of
+// its host, if there was one, and the nonce it answers
+func (d *digestAuth) authorization(req *http.Request) (string, string, bool) {
+	d.mu.Lock()
+	n, ok := d.hosts[req.URL.Host]
+	if ok {
+		n.count++
+	}
+	var count uint32
+	if ok {
+		count = n.count
+	}
+	d.mu.Unlock()
+	if !ok {
+		return "", "", false
+	}
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return "", "", false
+	}
+	return n.challenge.authorization(d.username, d.password, req.Method, req.URL.RequestURI(),
+		base64.RawURLEncoding.EncodeToString(cnonce), count), n.challenge.nonce, true
+}
+
+// authorization returns the Authorization header answering the challenge
+func (ch *digestChallenge) authorization(username, password, method, uri, cnonce string, nc uint32) string {
+	var h func() hash.Hash
+	alg := strings.ToUpper(ch.algorithm)
+	switch strings.TrimSuffix(alg, "-SESS") {
+	case "SHA-256":
+		h = sha256.New
+	default:
+		h = md5.New
+	}
+	hexHash := func(parts ...string) string {
+		hh := h()
+		hh.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	ha1 := hexHash(username, ch.realm, password)
+	if strings.HasSuffix(alg, "-SESS") {
+		ha1 = hexHash(ha1, ch.nonce, cnonce)
+	}
+	ha2 := hexHash(method, uri)
+	ncValue := fmt.Sprintf("%08x", nc)
+	var response string
+	if ch.qop == "" {
+		response = hexHash(ha1, ch.nonce, ha2)
+	} else {
+		response = hexHash(ha1, ch.nonce, ncValue, cnonce, ch.qop, ha2)
+	}
+
+	user := username
+	if ch.userhash {
+		user = hexHash(username, ch.realm)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%s, realm=%s, uri=%s, algorithm=%s, nonce=%s`,
+		quote(user), quote(ch.realm), quote(uri), ch.algorithm, quote(ch.nonce))
+	if ch.qop != "" {
+		fmt.Fprintf(&b, `, nc=%s, cnonce=%s, qop=%s`, ncValue, quote(cnonce), ch.qop)
+	}
+	fmt.Fprintf(&b, `, response=%s`, quote(response))
+	if ch.opaque != "" {
+		fmt.Fprintf(&b, `, opaque=%s`, quote(ch.opaque))
+	}
+	if ch.userhash {
+		b.WriteString(", userhash=true")
+	}
+	return b.String()
+}
+
+// quote returns s as a quoted-string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,240 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDigestChallengeAuthorization(t *testing.T) {
+	// RFC 7616 section 3.9.1
+	const (
+		nonce  = "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+		opaque = "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"
+		cnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	)
+	for alg, response := range map[string]string{
+		"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+		"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	} {
+		header := fmt.Sprintf(`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=%s, nonce="%s", opaque="%s"`,
+			alg, nonce, opaque)
+		ch, err := parseDigestChallenge([]string{`Basic realm="x"`, header})
+		if err != nil {
+			t.Fatalf("trouble when parsing %s challenge: %v", alg, err)
+		}
+		auth := ch.authorization("Mufasa", "Circle of Life", http.MethodGet, "/dir/index.html", cnonce, 1)
+		p := parseAuthParams(strings.TrimPrefix(auth, "Digest "))
+		for k, expected := range map[string]string{
+			"username":  "Mufasa",
+			"realm":     "http-auth@example.org",
+			"uri":       "/dir/index.html",
+			"algorithm": alg,
+			"nonce":     nonce,
+			"nc":        "00000001",
+			"cnonce":    cnonce,
+			"qop":       "auth",
+			"response":  response,
+			"opaque":    opaque,
+		} {
+			if p[k] != expected {
+				t.Errorf("%s: expected %s=%s, got %s", alg, k, expected, p[k])
+			}
+		}
+	}
+
+	ch, err := parseDigestChallenge([]string{
+		`Digest realm="r", qop="auth", algorithm=MD5, nonce="a"`,
+		`Digest realm="r", qop="auth", algorithm=SHA-256, nonce="b"`,
+	})
+	if err != nil || ch.algorithm != "SHA-256" {
+		t.Errorf("expected the SHA-256 challenge to be preferred, got %+v, %v", ch, err)
+	}
+	for _, header := range []string{
+		`Basic realm="r"`,
+		`Digest realm="r", qop="auth-int", nonce="a"`,
+		`Digest realm="r", algorithm=SHA-512-256, nonce="a"`,
+	} {
+		if _, err := parseDigestChallenge([]string{header}); err == nil {
+			t.Errorf("expected an error for challenge %s", header)
+		}
+	}
+}
+
+// digestServer requires Digest SHA-256 authentication, issuing a new nonce
+// after maxUses requests. With noStale, a request answering an old nonce is
+// challenged without stale=true, like some servers do.
+type digestServer struct {
+	mu         sync.Mutex
+	nonce      int
+	uses       int
+	maxUses    int
+	noStale    bool
+	challenges int
+	nc         []string
+}
+
+func (s *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nonce := fmt.Sprintf("nonce-%d", s.nonce)
+	challenge := func(stale bool) {
+		s.challenges++
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+			`Digest realm="test", qop="auth", algorithm=SHA-256, nonce="%s", opaque="o", stale=%t`, nonce, stale))
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		challenge(false)
+		return
+	}
+	p := parseAuthParams(strings.TrimPrefix(auth, "Digest "))
+	hexHash := func(parts ...string) string {
+		h := sha256.Sum256([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(h[:])
+	}
+	ha1 := hexHash(p["username"], "test", "secret")
+	expected := hexHash(ha1, p["nonce"], p["nc"], p["cnonce"], "auth", hexHash(r.Method, r.URL.RequestURI()))
+	if p["response"] != expected || p["opaque"] != "o" || p["uri"] != r.URL.RequestURI() {
+		challenge(false)
+		return
+	}
+	if p["nonce"] != nonce {
+		challenge(!s.noStale)
+		return
+	}
+	s.nc = append(s.nc, p["nc"])
+	if s.uses++; s.uses == s.maxUses {
+		s.nonce++
+		s.uses = 0
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+}
+
+func TestDigestAuth(t *testing.T) {
+	ds := &digestServer{maxUses: 2}
+	s := httptest.NewServer(ds)
+	defer s.Close()
+
+	post := func(c Client, body string) (int, string) {
+		var status int
+		var received string
+		rh := func(ctx context.Context, res *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(res.Body)
+			status, received = res.StatusCode, string(b)
+			return err
+		}
+		if err := c.Post(context.Background(), rh, s.URL+"/rooms?hotel=1", strings.NewReader(body)); err != nil {
+			t.Fatalf("trouble when making POST request: %v", err)
+		}
+		return status, received
+	}
+
+	var retries int
+	c, err := New(DigestAuth("Mufasa", "secret"), OnRetry(func(req *http.Request, info HookInfo) {
+		retries++
+	}))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < 3; i++ {
+		body := fmt.Sprintf("request %d", i)
+		if status, received := post(c, body); status != http.StatusOK || received != body {
+			t.Errorf("expected the request to be authorized, got %d %q", status, received)
+		}
+	}
+	// the first request was challenged, the second reused the nonce, the
+	// third one was answered a stale challenge
+	if strings.Join(ds.nc, ",") != "00000001,00000002,00000001" || ds.challenges != 2 {
+		t.Errorf("unexpected nonce counts %v after %d challenges", ds.nc, ds.challenges)
+	}
+	if retries != 2 {
+		t.Errorf("expected the challenged requests to be retried, got %d retries", retries)
+	}
+
+	// a nonce rotated without stale=true is answered, not replayed
+	rotating := &digestServer{maxUses: 1, noStale: true}
+	rs := httptest.NewServer(rotating)
+	defer rs.Close()
+	for i := 0; i < 3; i++ {
+		var status int
+		rh := func(ctx context.Context, res *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			status = res.StatusCode
+			return nil
+		}
+		if err := c.Get(context.Background(), rh, rs.URL); err != nil || status != http.StatusOK {
+			t.Errorf("expected the request to the rotating server to be authorized, got %d %v", status, err)
+		}
+	}
+	if rotating.challenges != 3 {
+		t.Errorf("expected a challenge per rotated nonce, got %d", rotating.challenges)
+	}
+
+	wrong, err := New(DigestAuth("Mufasa", "wrong"))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer wrong.Close()
+	if status, _ := post(wrong, "x"); status != http.StatusUnauthorized {
+		t.Errorf("expected wrong credentials to be rejected, got %d", status)
+	}
+
+	if _, err := New(DigestAuth("", "secret")); err != ErrInvalidOptionValue {
+		t.Errorf("expected ErrInvalidOptionValue, got %v", err)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		fmt.Fprintf(w, "%s:%s", user, pass)
+	}))
+	defer s.Close()
+
+	c, err := New(BasicAuth("Aladdin", "open sesame"))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	get := func(opts ...RequestOption) string {
+		var received string
+		rh := func(ctx context.Context, res *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(res.Body)
+			received = string(b)
+			return err
+		}
+		if err := c.Get(context.Background(), rh, s.URL, opts...); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+		return received
+	}
+	if received := get(); received != "Aladdin:open sesame" {
+		t.Errorf("expected the client credentials, got %s", received)
+	}
+	if received := get(SetBasicAuth("Jasmine", "carpet")); received != "Jasmine:carpet" {
+		t.Errorf("expected the request credentials, got %s", received)
+	}
+
+	if _, err := New(BasicAuth("a:b", "c")); err != ErrInvalidOptionValue {
+		t.Errorf("expected ErrInvalidOptionValue, got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDumpBodyLimit is the number of body bytes included in request and
+// response dumps
+const DefaultDumpBodyLimit = 64 << 10
+
+// dumpToKey is the context key of the DumpTo request option
+type dumpToKey struct{}
+
+// DumpTo is a RequestOption writing a transcript of the request and its
+// response to w, like the Debug option does for every request
+func DumpTo(w io.Writer) RequestOption {
+	return func(req *http.Request) error {
+		*req = *req.WithContext(context.WithValue(req.Context(), dumpToKey{}, w))
+		return nil
+	}
+}
+
+// dumpTransport writes transcripts of the requests going over the wire and
+// their responses. It sits right above the Transport, so the dumps show the
+// headers added by the other layers, e.g. tracing.
+type dumpTransport struct {
+	next      http.RoundTripper
+	w         io.Writer // Debug writer, may be nil
+	bodyLimit int64
+	redact    *redactor
+	seq       int64
+	mu        sync.Mutex // serializes writes of concurrent requests
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := t.w
+	if dw, ok := req.Context().Value(dumpToKey{}).(io.Writer); ok {
+		w = dw
+	}
+	if w == nil {
+		return t.next.RoundTrip(req)
+	}
+	seq := atomic.AddInt64(&t.seq, 1)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- request %d ---\r\n", seq)
+	fmt.Fprintf(&buf, "%s %s %s\r\n", req.Method, t.requestURI(req), req.Proto)
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&buf, "Host: %s\r\n", host)
+	t.redact.Header(req.Header).Write(&buf)
+	buf.WriteString("\r\n")
+	if req.Body != nil && req.Body != http.NoBody {
+		// a RoundTripper must not modify the request of the caller
+		req = req.Clone(req.Context())
+		var body []byte
+		var err error
+		body, req.Body, err = t.peek(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		t.writeBody(&buf, body, req.Header, true)
+	}
+	t.write(w, buf.Bytes())
+
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	buf.Reset()
+	fmt.Fprintf(&buf, "--- response %d (%s) ---\r\n", seq, time.Since(start))
+	if err != nil {
+		fmt.Fprintf(&buf, "error: %s\r\n", err)
+		t.write(w, buf.Bytes())
+		return res, err
+	}
+	fmt.Fprintf(&buf, "%s %s\r\n", res.Proto, res.Status)
+	t.redact.Header(res.Header).Write(&buf)
+	buf.WriteString("\r\n")
+	t.write(w, buf.Bytes())
+	if res.Body != nil && res.Body != http.NoBody {
+		// the body is dumped as it is read, so streams are not held back
+		res.Body = &dumpBody{ReadCloser: res.Body, t: t, w: w, seq: seq, header: res.Header}
+	}
+	return res, nil
+}
+
+// requestURI returns the redacted request target
+func (t *dumpTransport) requestURI(req *http.Request) string {
+	u := *req.URL
+	u.Scheme, u.Host, u.User = "", "", nil
+	uri := t.redact.URL(&u)
+	if uri == "" {
+		uri = "/"
+	}
+	return uri
+}
+
+// peek reads up to bodyLimit+1 bytes of body, returning them along with a
+// body that yields the complete content again
+func (t *dumpTransport) peek(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	return peekBody(body, t.bodyLimit)
+}
+
+// peekBody reads up to limit+1 bytes of body, returning them along with a
+// body that yields the complete content again
+func peekBody(body io.ReadCloser, limit int64) ([]byte, io.ReadCloser, error) {
+	b, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+	return b, &peekedBody{io.MultiReader(bytes.NewReader(b), body), body}, nil
+}
+
+// writeBody writes body, truncated to bodyLimit, and redacts JSON bodies that
+// were read in full
+func (t *dumpTransport) writeBody(buf *bytes.Buffer, body []byte, h http.Header, complete bool) {
+	truncated := int64(len(body)) > t.bodyLimit
+	if strings.Contains(h.Get("Content-Type"), "json") && len(t.redact.jsonFields) > 0 {
+		// a partial document cannot be redacted
+		if truncated {
+			fmt.Fprintf(buf, "[JSON body larger than %d bytes omitted]\r\n", t.bodyLimit)
+			return
+		}
+		if !complete {
+			buf.WriteString("[partially read JSON body omitted]\r\n")
+			return
+		}
+		body = t.redact.JSON(body)
+	}
+	if truncated {
+		body = body[:t.bodyLimit]
+	}
+	buf.Write(body)
+	if truncated {
+		fmt.Fprintf(buf, "\r\n[truncated after %d bytes]", t.bodyLimit)
+	}
+	buf.WriteString("\r\n")
+}
+
+func (t *dumpTransport) write(w io.Writer, b []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w.Write(b)
+}
+
+// peekedBody reads the peeked bytes followed by the rest of the original body
+type peekedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *peekedBody) Close() error {
+	return b.body.Close()
+}
+
+// dumpBody writes the response body to the dump once it is read in full or
+// closed, keeping up to bodyLimit+1 bytes
+type dumpBody struct {
+	io.ReadCloser
+	t      *dumpTransport
+	w      io.Writer
+	seq    int64
+	header http.Header
+	body   bytes.Buffer
+	n      int64
+	err    error // first error of Read, io.EOF once read in full
+	once   sync.Once
+}
+
+func (b *dumpBody) done() {
+	b.once.Do(func() {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "--- response %d body ---\r\n", b.seq)
+		b.t.writeBody(&buf, b.body.Bytes(), b.header, b.err == io.EOF)
+		switch {
+		case b.err == nil:
+			fmt.Fprintf(&buf, "[closed after %d bytes were read]\r\n", b.n)
+		case b.err != io.EOF:
+			fmt.Fprintf(&buf, "error after %d bytes: %s\r\n", b.n, b.err)
+		}
+		b.t.write(b.w, buf.Bytes())
+	})
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.t.bodyLimit + 1 - int64(b.body.Len()); room > 0 {
+		b.body.Write(p[:min(int64(n), room)])
+	}
+	b.n += int64(n)
+	if err != nil {
+		b.err = err
+		b.done()
+	}
+	return n, err
+}
+
+func (b *dumpBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
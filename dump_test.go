@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebug(t *testing.T) {
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			io.WriteString(w, strings.Repeat("x", 100))
+			return
+		case "/stream":
+			io.WriteString(w, "first ")
+			w.(http.Flusher).Flush()
+			select {
+			case <-release:
+				io.WriteString(w, "second")
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, r.Body)
+	}))
+	defer s.Close()
+
+	var debug bytes.Buffer
+	headers := http.Header{"Authorization": {"Bearer t0k3n"}}
+	c, err := New(
+		Debug(&debug),
+		DumpBodyLimit(64),
+		Headers(headers),
+		RedactJSONFields("password"),
+		RedactQueryParams("api_key"),
+	)
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	var received string
+	rh := func(ctx context.Context, resp *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		received = string(b)
+		return err
+	}
+	payload := `{"user":"bob","password":"hunter2"}`
+	err = c.Post(context.Background(), rh, s.URL+"/echo?api_key=s3cr3t", strings.NewReader(payload),
+		SetHeaders(http.Header{"Content-Type": {"application/json"}}))
+	if err != nil {
+		t.Fatalf("trouble when making POST request: %v", err)
+	}
+	if received != payload {
+		t.Errorf("expected the handler to read %s, got %s", payload, received)
+	}
+
+	dump := debug.String()
+	for _, expected := range []string{
+		"--- request 1 ---",
+		"POST /echo?api_key=" + RedactedValue + " HTTP/1.1",
+		"Authorization: " + RedactedValue,
+		`{"password":"` + RedactedValue + `","user":"bob"}`,
+		"--- response 1 (",
+		"HTTP/1.1 200 OK",
+		"Content-Type: application/json",
+		"--- response 1 body ---",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("expected %q in dump:\n%s", expected, dump)
+		}
+	}
+	for _, secret := range []string{"t0k3n", "hunter2", "s3cr3t"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("secret %q leaked into dump:\n%s", secret, dump)
+		}
+	}
+
+	// per request dump with a truncated body
+	var single bytes.Buffer
+	if err := c.Get(context.Background(), rh, s.URL+"/big", DumpTo(&single)); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if len(received) != 100 {
+		t.Errorf("expected the handler to read 100 bytes, got %d", len(received))
+	}
+	if !strings.Contains(single.String(), strings.Repeat("x", 64)+"\r\n[truncated after 64 bytes]") {
+		t.Errorf("expected truncated body in dump:\n%s", single.String())
+	}
+	if strings.Contains(debug.String(), "/big") {
+		t.Error("expected DumpTo to replace the Debug writer")
+	}
+
+	// streamed responses are handed over before their body is read
+	var streamed bytes.Buffer
+	stream := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		first := make([]byte, len("first "))
+		if _, err := io.ReadFull(res.Body, first); err != nil {
+			return err
+		}
+		if !strings.Contains(streamed.String(), "HTTP/1.1 200 OK") || strings.Contains(streamed.String(), "first") {
+			t.Errorf("expected only the response head to be dumped yet:\n%s", streamed.String())
+		}
+		close(release)
+		_, err = io.ReadAll(res.Body)
+		return err
+	}
+	if err := c.Get(context.Background(), stream, s.URL+"/stream", DumpTo(&streamed)); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if !strings.Contains(streamed.String(), "--- response 3 body ---\r\nfirst second\r\n") {
+		t.Errorf("expected the streamed body in dump:\n%s", streamed.String())
+	}
+
+	// the request of the caller is left untouched
+	dt := &dumpTransport{
+		next: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			io.Copy(io.Discard, req.Body)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		w:         io.Discard,
+		bodyLimit: DefaultDumpBodyLimit,
+		redact:    newRedactor(),
+	}
+	body := io.NopCloser(strings.NewReader(payload))
+	req, _ := http.NewRequest(http.MethodPost, s.URL, body)
+	if _, err := dt.RoundTrip(req); err != nil {
+		t.Fatalf("trouble when dumping the request: %v", err)
+	}
+	if req.Body != body {
+		t.Error("expected the body of the request not to be replaced")
+	}
+
+	if _, err := New(DumpBodyLimit(-1)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
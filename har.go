@@ -0,0 +1,487 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HARVersion is the version of the HTTP Archive format written by HARRecorder
+const HARVersion = "1.2"
+
+// HAR is an HTTP Archive, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of an HTTP Archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application that created the archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request and its response. Each request of a redirect
+// chain is an entry of its own.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // total time in milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"` // the ConnID
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest describes a request. HeadersSize is always -1, the size of the
+// headers on the wire is not known to the client.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse describes a response. Failed requests have a zero Status and
+// the error as Comment.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+// HARNameValue is a header or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie is a cookie sent with a request or set by a response
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// HARPostData is the body of a request
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent is the body of a response. Size is the number of bytes read by
+// the ResponseHandler, Text holds at most DumpBodyLimit of them.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings are the phases of a request in milliseconds, -1 for those that
+// did not happen. Connect includes the SSL time.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder captures the requests made by the clients it is given to with
+// the RecordHAR option, so the traffic can be opened in browser devtools or
+// shared as a HAR file. It is safe for concurrent use.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+// NewHARRecorder returns an empty HARRecorder
+func NewHARRecorder() *HARRecorder {
+	return new(HARRecorder)
+}
+
+// HAR returns the archive of the requests recorded so far. Requests are
+// recorded once their response body was read to EOF or closed.
+func (r *HARRecorder) HAR() HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]HAREntry, len(r.entries))
+	for i, e := range r.entries {
+		entries[i] = *e
+	}
+	return HAR{HARLog{
+		Version: HARVersion,
+		Creator: HARCreator{Name: "httpclient", Version: moduleVersion()},
+		Entries: entries,
+	}}
+}
+
+// moduleVersion returns the version of this module in the build, "(devel)"
+// if it is not known, e.g. in its own tests
+func moduleVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == instrumentationName && info.Main.Version != "" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == instrumentationName {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+// WriteTo writes the archive as JSON to w
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// WriteFile writes the archive to the named file, readable by the owner only
+// as it may hold sensitive data the redaction policy did not cover
+func (r *HARRecorder) WriteFile(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Reset discards the recorded requests
+func (r *HARRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// harTransport records every request going over the wire and its response
+// to a HARRecorder
+type harTransport struct {
+	next      http.RoundTripper
+	rec       *HARRecorder
+	bodyLimit int64
+	redact    *redactor
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timer := newTimer()
+	var serverIP string
+	ctx := httptrace.WithClientTrace(req.Context(), timer.clientTrace())
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			serverIP, _, _ = net.SplitHostPort(info.Conn.RemoteAddr().String())
+		},
+	})
+	// a RoundTripper must not modify the request of the caller
+	req = req.Clone(ctx)
+
+	entry := &HAREntry{
+		StartedDateTime: timer.start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Request:         t.request(req),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		var body []byte
+		var err error
+		body, req.Body, err = peekBody(req.Body, t.bodyLimit)
+		if err != nil {
+			return nil, err
+		}
+		mimeType := req.Header.Get("Content-Type")
+		text, encoding, comment := t.text(body, mimeType)
+		if encoding != "" {
+			// postData has no encoding, binary bodies cannot be included
+			text, comment = "", fmt.Sprintf("binary body of %d bytes omitted", len(body))
+		}
+		entry.Request.PostData = &HARPostData{MimeType: mimeType, Text: text, Comment: comment}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	entry.ServerIPAddress = serverIP
+	if err != nil {
+		entry.Request.HTTPVersion = req.Proto
+		entry.Response = HARResponse{
+			Cookies:     []HARCookie{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+			Comment:     err.Error(),
+		}
+		t.finish(entry, timer, -1)
+		return res, err
+	}
+	entry.Request.HTTPVersion = res.Proto
+	entry.Response = t.response(res)
+	if res.Body == nil || res.Body == http.NoBody {
+		t.finish(entry, timer, 0)
+		return res, nil
+	}
+	res.Body = &harBody{ReadCloser: res.Body, t: t, entry: entry, timer: timer}
+	return res, nil
+}
+
+// finish completes the timings of entry and adds it to the recorder
+func (t *harTransport) finish(entry *HAREntry, timer *timer, bodySize int64) {
+	timer.set(&timer.bodyDone)
+	t.rec.mu.Lock()
+	defer t.rec.mu.Unlock()
+	if bodySize >= 0 {
+		entry.Response.BodySize = bodySize
+		entry.Response.Content.Size = bodySize
+	}
+	t.timings(entry, timer)
+	t.rec.entries = append(t.rec.entries, entry)
+}
+
+func (t *harTransport) request(req *http.Request) HARRequest {
+	u := t.redact.URL(req.URL)
+	query := []HARNameValue{}
+	if parsed, err := req.URL.Parse(u); err == nil {
+		query = harNameValues(parsed.Query())
+	}
+	cookies := []HARCookie{}
+	for _, c := range req.Cookies() {
+		cookies = append(cookies, HARCookie{Name: c.Name, Value: t.cookieValue("Cookie", c.Value)})
+	}
+	bodySize := req.ContentLength
+	if req.Body == nil || req.Body == http.NoBody {
+		bodySize = 0
+	} else if bodySize == 0 {
+		bodySize = -1
+	}
+	return HARRequest{
+		Method:      req.Method,
+		URL:         u,
+		HTTPVersion: req.Proto,
+		Cookies:     cookies,
+		Headers:     harNameValues(t.redact.Header(req.Header)),
+		QueryString: query,
+		HeadersSize: -1,
+		BodySize:    bodySize,
+	}
+}
+
+func (t *harTransport) response(res *http.Response) HARResponse {
+	cookies := []HARCookie{}
+	for _, c := range res.Cookies() {
+		hc := HARCookie{
+			Name:     c.Name,
+			Value:    t.cookieValue("Set-Cookie", c.Value),
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+		}
+		cookies = append(cookies, hc)
+	}
+	var redirectURL string
+	if loc, err := res.Location(); err == nil {
+		redirectURL = t.redact.URL(loc)
+	}
+	return HARResponse{
+		Status:      res.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(res.Status, strconv.Itoa(res.StatusCode))),
+		HTTPVersion: res.Proto,
+		Cookies:     cookies,
+		Headers:     harNameValues(t.redact.Header(res.Header)),
+		Content:     HARContent{MimeType: res.Header.Get("Content-Type")},
+		RedirectURL: redirectURL,
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+}
+
+// cookieValue redacts the value of a cookie if the header it came with is
+func (t *harTransport) cookieValue(header, value string) string {
+	if t.redact.headers[header] {
+		return RedactedValue
+	}
+	return value
+}
+
+// text returns body as the text of a HAR entry, truncated to bodyLimit and
+// base64 encoded unless it is textual. JSON bodies read in full are redacted.
+func (t *harTransport) text(body []byte, mimeType string) (text, encoding, comment string) {
+	truncated := int64(len(body)) > t.bodyLimit
+	if strings.Contains(mimeType, "json") && len(t.redact.jsonFields) > 0 {
+		if truncated {
+			// a partial document cannot be redacted
+			return "", "", fmt.Sprintf("JSON body larger than %d bytes omitted", t.bodyLimit)
+		}
+		body = t.redact.JSON(body)
+	}
+	if truncated {
+		body = body[:t.bodyLimit]
+		comment = fmt.Sprintf("truncated after %d bytes", t.bodyLimit)
+	}
+	if mimeType == "" {
+		// sniffed like net/http does for responses without a Content-Type
+		mimeType = http.DetectContentType(body)
+	}
+	if !isTextual(mimeType) && len(body) > 0 {
+		return base64.StdEncoding.EncodeToString(body), "base64", comment
+	}
+	return string(body), "", comment
+}
+
+// timings sets the HAR timings of entry from timer
+func (t *harTransport) timings(entry *HAREntry, timer *timer) {
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	optional := func(start, end time.Time) float64 {
+		if start.IsZero() {
+			return -1
+		}
+		return ms(between(start, end))
+	}
+	dns := between(timer.dnsStart, timer.dnsDone)
+	connect := between(timer.connectStart, timer.connectDone) + between(timer.tlsStart, timer.tlsDone)
+	blocked := between(timer.start, timer.gotConn) - dns - connect
+	if blocked < 0 {
+		blocked = 0
+	}
+	ht := HARTimings{
+		Blocked: ms(blocked),
+		DNS:     optional(timer.dnsStart, timer.dnsDone),
+		Connect: -1,
+		Send:    ms(between(timer.gotConn, timer.wroteRequest)),
+		Wait:    ms(between(timer.wroteRequest, timer.firstByte)),
+		Receive: ms(between(timer.firstByte, timer.bodyDone)),
+		SSL:     optional(timer.tlsStart, timer.tlsDone),
+	}
+	if !timer.connectStart.IsZero() {
+		ht.Connect = ms(connect)
+	}
+	entry.Timings = ht
+	entry.Time = ms(blocked+dns+connect) + ht.Send + ht.Wait + ht.Receive
+	if timer.connID != 0 {
+		entry.Connection = strconv.FormatInt(timer.connID, 10)
+	}
+}
+
+// harBody keeps the first bytes of a response body as the caller reads it,
+// counts them and completes its HAR entry once it was read to EOF or closed
+type harBody struct {
+	io.ReadCloser
+	t     *harTransport
+	entry *HAREntry
+	timer *timer
+	body  bytes.Buffer // up to bodyLimit+1 bytes
+	n     int64
+	eof   bool
+	once  sync.Once
+}
+
+func (b *harBody) done() {
+	b.once.Do(func() {
+		content := &b.entry.Response.Content
+		content.Text, content.Encoding, content.Comment = b.t.text(b.body.Bytes(), content.MimeType)
+		if !b.eof && content.Comment == "" {
+			content.Comment = fmt.Sprintf("closed after %d bytes were read", b.n)
+		}
+		b.t.finish(b.entry, b.timer, b.n)
+	})
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.t.bodyLimit + 1 - int64(b.body.Len()); room > 0 {
+		b.body.Write(p[:min(int64(n), room)])
+	}
+	b.n += int64(n)
+	if errors.Is(err, io.EOF) {
+		b.eof = true
+		b.done()
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+// harNameValues returns the values of m sorted by name
+func harNameValues(m map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nvs := []HARNameValue{}
+	for _, name := range names {
+		for _, v := range m[name] {
+			nvs = append(nvs, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return nvs
+}
+
+// isTextual reports whether bodies of the given media type are text
+func isTextual(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "x-www-form-urlencoded"} {
+		if strings.Contains(mimeType, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordHAR(t *testing.T) {
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/stream":
+			io.WriteString(w, "first ")
+			w.(http.Flusher).Flush()
+			select {
+			case <-release:
+				io.WriteString(w, "second")
+			case <-time.After(5 * time.Second):
+			}
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"token":"t0k3n","id":1}`)
+		}
+	}))
+	defer s.Close()
+
+	rec := NewHARRecorder()
+	c, err := New(RecordHAR(rec), RedactJSONFields("token", "password"), RedactQueryParams("api_key"))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.Get(ctx, NoopResponseHandler, s.URL+"/redirect?api_key=k"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	err = c.Post(ctx, NoopResponseHandler, s.URL+"/echo", strings.NewReader(`{"password":"hunter2"}`),
+		SetHeaders(http.Header{"Content-Type": {"application/json"}, "Authorization": {"Bearer t0k3n"}}))
+	if err != nil {
+		t.Fatalf("trouble when making POST request: %v", err)
+	}
+	if err := c.Get(ctx, NoopResponseHandler, s.URL+"/image"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+
+	har := rec.HAR()
+	if har.Log.Version != HARVersion {
+		t.Errorf("expected version %s, got %s", HARVersion, har.Log.Version)
+	}
+	if creator := har.Log.Creator; creator.Name != "httpclient" || creator.Version != "(devel)" {
+		t.Errorf("expected the version of httpclient as creator, got %+v", creator)
+	}
+	entries := har.Log.Entries
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	redirect := entries[0]
+	if redirect.Request.URL != s.URL+"/redirect?api_key="+RedactedValue {
+		t.Errorf("expected the redacted URL, got %s", redirect.Request.URL)
+	}
+	if q := redirect.Request.QueryString; len(q) != 1 || q[0].Value != RedactedValue {
+		t.Errorf("expected the redacted query string, got %v", q)
+	}
+	if redirect.Response.Status != http.StatusFound || redirect.Response.RedirectURL != s.URL+"/echo" {
+		t.Errorf("expected a redirect to /echo, got %d %s", redirect.Response.Status, redirect.Response.RedirectURL)
+	}
+	if redirect.Timings.DNS != -1 || redirect.Timings.Connect < 0 || redirect.Timings.SSL != -1 {
+		t.Errorf("expected a plain TCP connection to an IP, got %+v", redirect.Timings)
+	}
+	if redirect.ServerIPAddress != "127.0.0.1" || redirect.Connection != "1" {
+		t.Errorf("expected connection 1 to 127.0.0.1, got %s %s", redirect.Connection, redirect.ServerIPAddress)
+	}
+
+	followed := entries[1]
+	if followed.Timings.Connect != -1 {
+		t.Errorf("expected the connection to be reused, got %+v", followed.Timings)
+	}
+	if followed.Response.Content.Text != `{"id":1,"token":"REDACTED"}` || followed.Response.Content.Size != 24 {
+		t.Errorf("expected the redacted body of 24 bytes, got %+v", followed.Response.Content)
+	}
+	if cookies := followed.Response.Cookies; len(cookies) != 1 || cookies[0].Value != RedactedValue {
+		t.Errorf("expected the redacted session cookie, got %v", cookies)
+	}
+
+	post := entries[2]
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"password":"REDACTED"}` {
+		t.Errorf("expected the redacted post data, got %+v", post.Request.PostData)
+	}
+	for _, h := range post.Request.Headers {
+		if h.Name == "Authorization" && h.Value != RedactedValue {
+			t.Errorf("expected the Authorization header to be redacted, got %s", h.Value)
+		}
+	}
+
+	image := entries[3].Response.Content
+	if image.Encoding != "base64" || image.Text != "iVBORw==" {
+		t.Errorf("expected the base64 encoded image, got %+v", image)
+	}
+
+	name := filepath.Join(t.TempDir(), "session.har")
+	if err := rec.WriteFile(name); err != nil {
+		t.Fatalf("trouble when writing the HAR file: %v", err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded HAR
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("invalid HAR file: %v", err)
+	}
+	if len(decoded.Log.Entries) != 4 {
+		t.Errorf("expected 4 entries in the file, got %d", len(decoded.Log.Entries))
+	}
+
+	rec.Reset()
+	if n := len(rec.HAR().Log.Entries); n != 0 {
+		t.Errorf("expected no entries after Reset, got %d", n)
+	}
+
+	// the response is returned before its body is read
+	stream := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		first := make([]byte, len("first "))
+		if _, err := io.ReadFull(res.Body, first); err != nil {
+			return err
+		}
+		close(release)
+		_, err = io.ReadAll(res.Body)
+		return err
+	}
+	if err := c.Get(ctx, stream, s.URL+"/stream"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if entries := rec.HAR().Log.Entries; len(entries) != 1 || entries[0].Response.Content.Text != "first second" {
+		t.Errorf("expected the streamed body to be recorded as it was read, got %+v", entries)
+	}
+	if _, err := New(RecordHAR(nil)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
+
+func TestHARText(t *testing.T) {
+	ht := &harTransport{bodyLimit: DefaultDumpBodyLimit, redact: newRedactor()}
+	tests := []struct {
+		body     string
+		mimeType string
+		text     string
+		encoding string
+	}{
+		{"hello", "", "hello", ""},
+		{"\xff\x00\xfe", "", "/wD+", "base64"},
+		{`{"a":1}`, "application/json", `{"a":1}`, ""},
+		{"\x89PNG", "image/png", "iVBORw==", "base64"},
+	}
+	for _, tc := range tests {
+		text, encoding, _ := ht.text([]byte(tc.body), tc.mimeType)
+		if text != tc.text || encoding != tc.encoding {
+			t.Errorf("expected %q %q for %q, got %q %q", tc.text, tc.encoding, tc.mimeType, text, encoding)
+		}
+	}
+}
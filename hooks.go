@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HookInfo is the context passed to the lifecycle hooks of a request made
+// with Client.Do
+type HookInfo struct {
+	// Attempt is 1 for the first attempt, incremented by every retry
+	Attempt int
+	// Elapsed time since Client.Do was called
+	Elapsed time.Duration
+	// ConnID of the connection the request was sent on, zero before it got
+	// one. It matches the IDs in the client's logs and ConnCloseEvents.
+	ConnID int64
+	// Reused is true if the request was sent on a pooled connection
+	Reused bool
+	// URL is the URL of the request, the final one after redirects
+	URL *url.URL
+	// Redirects followed so far
+	Redirects int
+}
+
+// hookStateKey is the context key of a request's hookState
+type hookStateKey struct{}
+
+// hookState is the attempt and redirect count of a request. Attempts and
+// redirects happen one after the other, so it needs no locking.
+type hookState struct {
+	attempt   int
+	redirects int
+	timer     *timer
+	hooks     *hooks // of the client
+}
+
+// hooks are the lifecycle hooks given with the On* options
+type hooks struct {
+	onRequest  []func(*http.Request, HookInfo) error
+	onResponse []func(*http.Response, HookInfo)
+	onError    []func(error, HookInfo)
+	onRetry    []func(*http.Request, HookInfo)
+	onRedirect []func(*http.Request, []*http.Request, HookInfo) error
+}
+
+// info returns the HookInfo of a request with the given context and URL
+func (h *hooks) info(ctx context.Context, u *url.URL) HookInfo {
+	info := HookInfo{URL: u}
+	if s, ok := ctx.Value(hookStateKey{}).(*hookState); ok {
+		t := s.timer.timings()
+		info.Attempt = s.attempt
+		info.Redirects = s.redirects
+		info.Elapsed = t.Total
+		info.ConnID = t.ConnID
+		info.Reused = t.Reused
+	}
+	return info
+}
+
+// request calls the OnRequest hooks, stopping at the first error
+func (h *hooks) request(req *http.Request) error {
+	if len(h.onRequest) == 0 {
+		return nil
+	}
+	info := h.info(req.Context(), req.URL)
+	for _, f := range h.onRequest {
+		if err := f(req, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// response calls the OnResponse or OnError hooks with the outcome of req
+func (h *hooks) response(req *http.Request, res *http.Response, err error) {
+	if err != nil {
+		if len(h.onError) == 0 {
+			return
+		}
+		info := h.info(req.Context(), req.URL)
+		for _, f := range h.onError {
+			f(err, info)
+		}
+		return
+	}
+	if len(h.onResponse) == 0 {
+		return
+	}
+	info := h.info(req.Context(), res.Request.URL)
+	for _, f := range h.onResponse {
+		f(res, info)
+	}
+}
+
+// retry counts a new attempt of req and calls the OnRetry hooks
+func (h *hooks) retry(req *http.Request) {
+	s, ok := req.Context().Value(hookStateKey{}).(*hookState)
+	if ok {
+		s.attempt++
+	}
+	if len(h.onRetry) == 0 {
+		return
+	}
+	info := h.info(req.Context(), req.URL)
+	for _, f := range h.onRetry {
+		f(req, info)
+	}
+}
+
+// NotifyRetry is called by a Middleware before it sends req again, e.g. to
+// retry a failed request, so that the OnRetry hooks of the client are called
+// and the Attempt of the HookInfo counts it. It does nothing for requests that
+// were not made with Client.Do.
+func NotifyRetry(req *http.Request) {
+	if s, ok := req.Context().Value(hookStateKey{}).(*hookState); ok {
+		s.hooks.retry(req)
+	}
+}
+
+// redirect counts a redirect and calls the OnRedirect hooks, stopping at the
+// first error
+func (h *hooks) redirect(req *http.Request, via []*http.Request) error {
+	if s, ok := req.Context().Value(hookStateKey{}).(*hookState); ok {
+		s.redirects = len(via)
+	}
+	if len(h.onRedirect) == 0 {
+		return nil
+	}
+	info := h.info(req.Context(), req.URL)
+	for _, f := range h.onRedirect {
+		if err := f(req, via, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
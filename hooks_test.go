@@ -0,0 +1,131 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/final":
+			w.Header().Set("X-Audit", r.Header.Get("X-Audit"))
+		}
+	}))
+	defer s.Close()
+
+	var calls []string
+	var redirected, responded HookInfo
+	errAbort := errors.New("abort")
+	c, err := New(
+		OnRequest(func(req *http.Request, info HookInfo) error {
+			calls = append(calls, "request")
+			if info.Attempt != 1 || info.ConnID != 0 {
+				t.Errorf("expected the first attempt without a connection, got %+v", info)
+			}
+			if req.URL.Path == "/abort" {
+				return errAbort
+			}
+			req.Header.Set("X-Audit", "yes")
+			return nil
+		}),
+		OnRedirect(func(req *http.Request, via []*http.Request, info HookInfo) error {
+			calls = append(calls, "redirect")
+			redirected = info
+			return nil
+		}),
+		OnResponse(func(res *http.Response, info HookInfo) {
+			calls = append(calls, "response")
+			responded = info
+		}),
+		OnError(func(err error, info HookInfo) {
+			calls = append(calls, "error")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	var audit string
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		audit = res.Header.Get("X-Audit")
+		return nil
+	}
+	if err := c.Get(context.Background(), rh, s.URL+"/redirect"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if audit != "yes" {
+		t.Errorf("expected the header set by OnRequest to be sent, got %q", audit)
+	}
+	if redirected.Redirects != 1 || redirected.URL.Path != "/final" || redirected.ConnID != 1 {
+		t.Errorf("unexpected redirect info %+v", redirected)
+	}
+	if responded.Redirects != 1 || responded.URL.Path != "/final" || !responded.Reused || responded.Elapsed <= 0 {
+		t.Errorf("unexpected response info %+v", responded)
+	}
+
+	if err := c.Get(context.Background(), rh, s.URL+"/abort"); !errors.Is(err, errAbort) {
+		t.Errorf("expected OnRequest to abort the request, got %v", err)
+	}
+	if err := c.Get(context.Background(), rh, "http://127.0.0.1:1/"); err == nil {
+		t.Errorf("expected the request to fail")
+	}
+
+	expected := []string{"request", "redirect", "response", "request", "request", "error"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected hook calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected hook calls %v, got %v", expected, calls)
+			break
+		}
+	}
+
+	// retries of a middleware
+	var retried []int
+	var attempts int
+	retrying, err := New(
+		Use(func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				for i := 0; i < 2; i++ {
+					NotifyRetry(req)
+				}
+				return next.RoundTrip(req)
+			})
+		}),
+		OnRetry(func(req *http.Request, info HookInfo) {
+			retried = append(retried, info.Attempt)
+		}),
+		OnResponse(func(res *http.Response, info HookInfo) {
+			attempts = info.Attempt
+		}),
+	)
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer retrying.Close()
+	if err := retrying.Get(context.Background(), rh, s.URL+"/final"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if len(retried) != 2 || retried[0] != 2 || retried[1] != 3 || attempts != 3 {
+		t.Errorf("expected attempts 2 and 3 to be retries, got %v and %d attempts", retried, attempts)
+	}
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	NotifyRetry(req) // not made with Client.Do
+
+	for _, opt := range []Option{OnRequest(nil), OnResponse(nil), OnError(nil), OnRetry(nil), OnRedirect(nil)} {
+		if _, err := New(opt); err != ErrInvalidOptionValue {
+			t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package httpclientprom exports the measurements of httpclient.Client as
+// Prometheus metrics, so that every service embedding the client uses the
+// same metric names.
+//
+//	col := httpclientprom.NewCollector(httpclientprom.Namespace("myservice"))
+//	prometheus.MustRegister(col)
+//	client, err := httpclient.New(httpclient.WithMetricsRecorder(col))
+package httpclientprom
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gadventures/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace of the exported metrics
+const DefaultNamespace = "httpclient"
+
+// Collector is a prometheus.Collector and an httpclient.MetricsRecorder. It
+// can be passed to several Clients, in which case their measurements and pool
+// gauges are added up.
+type Collector struct {
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	inFlight         *prometheus.GaugeVec
+	dialDuration     *prometheus.HistogramVec
+	dialFailures     *prometheus.CounterVec
+	handshakeLatency *prometheus.HistogramVec
+
+	conns        *prometheus.Desc
+	connsClosed  *prometheus.Desc
+	dials        *prometheus.Desc
+	bytesRead    *prometheus.Desc
+	bytesWritten *prometheus.Desc
+
+	mu     sync.Mutex // guards below
+	stats  map[int]func() httpclient.Stats
+	lastID int
+	// retired are the counters of the unregistered clients per host, which
+	// keep counting in the totals
+	retired map[string]httpclient.ConnStats
+}
+
+// ensure interface implementations
+var (
+	_ prometheus.Collector       = &Collector{}
+	_ httpclient.MetricsRecorder = &Collector{}
+	_ httpclient.StatsRecorder   = &Collector{}
+)
+
+type config struct {
+	namespace   string
+	subsystem   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// Option configures the Collector
+type Option func(*config)
+
+// Namespace changes the namespace of the metric names (httpclient by default)
+func Namespace(ns string) Option {
+	return func(c *config) {
+		c.namespace = ns
+	}
+}
+
+// Subsystem sets the subsystem of the metric names
+func Subsystem(s string) Option {
+	return func(c *config) {
+		c.subsystem = s
+	}
+}
+
+// ConstLabels are added to all metrics, e.g. to tell several Collectors apart
+func ConstLabels(l prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = l
+	}
+}
+
+// Buckets changes the buckets of the duration histograms (in seconds), the
+// default is prometheus.DefBuckets
+func Buckets(b []float64) Option {
+	return func(c *config) {
+		c.buckets = b
+	}
+}
+
+// NewCollector returns a new Collector configured with the given Options
+func NewCollector(opts ...Option) *Collector {
+	cfg := &config{
+		namespace: DefaultNamespace,
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	name := func(n string) string {
+		return prometheus.BuildFQName(cfg.namespace, cfg.subsystem, n)
+	}
+	desc := func(n, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(name(n), help, labels, cfg.constLabels)
+	}
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "requests_total",
+			Help:        "Requests made by the client by method, host and status class.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"method", "host", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Time until the response headers were received.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"method", "host", "code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "requests_in_flight",
+			Help:        "Requests waiting for the response headers.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"host"}),
+		dialDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "dial_duration_seconds",
+			Help:        "Time spent dialing connections.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"host"}),
+		dialFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "dial_failures_total",
+			Help:        "Dials that returned an error.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"host"}),
+		handshakeLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "tls_handshake_duration_seconds",
+			Help:        "Time spent in TLS handshakes.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"host", "result"}),
+		conns:        desc("connections", "Open connections by state.", "host", "state"),
+		connsClosed:  desc("connections_closed_total", "Connections closed.", "host"),
+		dials:        desc("dials_total", "Dials attempted.", "host"),
+		bytesRead:    desc("read_bytes_total", "Bytes read from connections.", "host"),
+		bytesWritten: desc("written_bytes_total", "Bytes written to connections.", "host"),
+	}
+}
+
+// StatusClass returns the code label of a response status, e.g. 2xx, or
+// "error" if the request failed
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "error"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// RequestStarted implements httpclient.MetricsRecorder
+func (c *Collector) RequestStarted(method, host string) {
+	c.inFlight.WithLabelValues(host).Inc()
+}
+
+// RequestDone implements httpclient.MetricsRecorder
+func (c *Collector) RequestDone(m httpclient.RequestMetrics) {
+	c.inFlight.WithLabelValues(m.Host).Dec()
+	code := StatusClass(m.StatusCode)
+	if m.Err != nil {
+		code = "error"
+	}
+	c.requests.WithLabelValues(m.Method, m.Host, code).Inc()
+	c.requestDuration.WithLabelValues(m.Method, m.Host, code).Observe(m.Duration.Seconds())
+}
+
+// Dialed implements httpclient.MetricsRecorder
+func (c *Collector) Dialed(m httpclient.DialMetrics) {
+	host := dialHost(m.Addr)
+	c.dialDuration.WithLabelValues(host).Observe(m.Duration.Seconds())
+	if m.Err != nil {
+		c.dialFailures.WithLabelValues(host).Inc()
+	}
+}
+
+// TLSHandshakeDone implements httpclient.MetricsRecorder
+func (c *Collector) TLSHandshakeDone(m httpclient.TLSHandshakeMetrics) {
+	result := "ok"
+	if m.Err != nil {
+		result = "error"
+	}
+	c.handshakeLatency.WithLabelValues(m.Host, result).Observe(m.Duration.Seconds())
+}
+
+// RegisterStats implements httpclient.StatsRecorder
+func (c *Collector) RegisterStats(stats func() httpclient.Stats) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats == nil {
+		c.stats = make(map[int]func() httpclient.Stats)
+	}
+	c.lastID++
+	id := c.lastID
+	c.stats[id] = stats
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		stats, ok := c.stats[id]
+		if !ok {
+			return
+		}
+		delete(c.stats, id)
+		if c.retired == nil {
+			c.retired = make(map[string]httpclient.ConnStats)
+		}
+		for addr, hs := range stats().Hosts {
+			host := dialHost(addr)
+			total := c.retired[host]
+			total.Closed += hs.Closed
+			total.Dials += hs.Dials
+			total.BytesRead += hs.BytesRead
+			total.BytesWritten += hs.BytesWritten
+			c.retired[host] = total
+		}
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.requestDuration.Describe(ch)
+	c.inFlight.Describe(ch)
+	c.dialDuration.Describe(ch)
+	c.dialFailures.Describe(ch)
+	c.handshakeLatency.Describe(ch)
+	ch <- c.conns
+	ch <- c.connsClosed
+	ch <- c.dials
+	ch <- c.bytesRead
+	ch <- c.bytesWritten
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.requestDuration.Collect(ch)
+	c.inFlight.Collect(ch)
+	c.dialDuration.Collect(ch)
+	c.dialFailures.Collect(ch)
+	c.handshakeLatency.Collect(ch)
+
+	// pool gauges added up over all registered clients, and counters over
+	// the unregistered ones too so that they never go backwards
+	c.mu.Lock()
+	hosts := make(map[string]httpclient.ConnStats, len(c.retired))
+	for host, hs := range c.retired {
+		hosts[host] = hs
+	}
+	for _, stats := range c.stats {
+		for addr, hs := range stats().Hosts {
+			host := dialHost(addr)
+			total := hosts[host]
+			total.Open += hs.Open
+			total.Active += hs.Active
+			total.Idle += hs.Idle
+			total.Closed += hs.Closed
+			total.Dials += hs.Dials
+			total.BytesRead += hs.BytesRead
+			total.BytesWritten += hs.BytesWritten
+			hosts[host] = total
+		}
+	}
+	c.mu.Unlock()
+	for host, hs := range hosts {
+		ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(hs.Active), host, "active")
+		ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(hs.Idle), host, "idle")
+		ch <- prometheus.MustNewConstMetric(c.connsClosed, prometheus.CounterValue, float64(hs.Closed), host)
+		ch <- prometheus.MustNewConstMetric(c.dials, prometheus.CounterValue, float64(hs.Dials), host)
+		ch <- prometheus.MustNewConstMetric(c.bytesRead, prometheus.CounterValue, float64(hs.BytesRead), host)
+		ch <- prometheus.MustNewConstMetric(c.bytesWritten, prometheus.CounterValue, float64(hs.BytesWritten), host)
+	}
+}
+
+// dialHost returns the host of a dialed address, matching the host label of
+// the request metrics for default ports
+func dialHost(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if port == "80" || port == "443" {
+		return host
+	}
+	return addr
+}
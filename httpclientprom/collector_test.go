@@ -0,0 +1,138 @@
+package httpclientprom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gadventures/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	col := NewCollector(Namespace("test"))
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(col); err != nil {
+		t.Fatalf("register collector: %v", err)
+	}
+	c, err := httpclient.New(httpclient.WithMetricsRecorder(col))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	for _, path := range []string{"/", "/", "/missing"} {
+		if err := c.Get(ctx, httpclient.NoopResponseHandler, s.URL+path); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+	}
+	// the test server's certificate is not trusted, so the handshake fails
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	tlsHost := strings.TrimPrefix(tlsServer.URL, "https://")
+	if err := c.Get(ctx, httpclient.NoopResponseHandler, tlsServer.URL); err == nil {
+		t.Error("Expected an error but got nothing")
+	}
+
+	tests := []struct {
+		name     string
+		c        prometheus.Collector
+		expected float64
+	}{
+		{"2xx requests", col.requests.WithLabelValues("GET", host, "2xx"), 2},
+		{"4xx requests", col.requests.WithLabelValues("GET", host, "4xx"), 1},
+		{"failed requests", col.requests.WithLabelValues("GET", tlsHost, "error"), 1},
+		{"in flight", col.inFlight.WithLabelValues(host), 0},
+		{"dial failures", col.dialFailures.WithLabelValues(host), 0},
+	}
+	for _, tc := range tests {
+		if v := testutil.ToFloat64(tc.c); v != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, v)
+		}
+	}
+
+	// histograms and pool gauges
+	gather := func() map[string]float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("gather: %v", err)
+		}
+		samples := make(map[string]float64)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				labels := make([]string, 0, len(m.GetLabel()))
+				for _, l := range m.GetLabel() {
+					labels = append(labels, l.GetName()+"="+l.GetValue())
+				}
+				key := mf.GetName() + "{" + strings.Join(labels, ",") + "}"
+				switch {
+				case m.GetHistogram() != nil:
+					samples[key] = float64(m.GetHistogram().GetSampleCount())
+				case m.GetGauge() != nil:
+					samples[key] = m.GetGauge().GetValue()
+				case m.GetCounter() != nil:
+					samples[key] = m.GetCounter().GetValue()
+				}
+			}
+		}
+		return samples
+	}
+	samples := gather()
+	for key, expected := range map[string]float64{
+		"test_request_duration_seconds{code=2xx,host=" + host + ",method=GET}":   2,
+		"test_dial_duration_seconds{host=" + host + "}":                          1,
+		"test_tls_handshake_duration_seconds{host=" + tlsHost + ",result=error}": 1,
+		"test_connections{host=" + host + ",state=idle}":                         1,
+		"test_connections{host=" + host + ",state=active}":                       0,
+		"test_dials_total{host=" + host + "}":                                    1,
+	} {
+		if v, ok := samples[key]; !ok || v != expected {
+			t.Errorf("expected %s to be %v, got %v (present: %t)", key, expected, v, ok)
+		}
+	}
+
+	// the counters of a closed client keep their totals
+	read := samples["test_read_bytes_total{host="+host+"}"]
+	c.Close()
+	c.Close()
+	if n := len(col.stats); n != 0 {
+		t.Errorf("expected the closed client to be unregistered, got %d clients", n)
+	}
+	samples = gather()
+	if v := samples["test_dials_total{host="+host+"}"]; v != 1 {
+		t.Errorf("expected the dials of the closed client to be kept, got %v", v)
+	}
+	if v := samples["test_read_bytes_total{host="+host+"}"]; v != read || read == 0 {
+		t.Errorf("expected %v read bytes to be kept, got %v", read, v)
+	}
+	if v := samples["test_connections{host="+host+",state=idle}"]; v != 0 {
+		t.Errorf("expected no idle connection of the closed client, got %v", v)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for code, expected := range map[int]string{
+		0:   "error",
+		200: "2xx",
+		301: "3xx",
+		404: "4xx",
+		503: "5xx",
+		600: "error",
+	} {
+		if class := StatusClass(code); class != expected {
+			t.Errorf("expected %d to be %s, got %s", code, expected, class)
+		}
+	}
+}
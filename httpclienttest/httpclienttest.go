@@ -0,0 +1,72 @@
+// Package httpclienttest provides test doubles for code using
+// httpclient.Client: a mock Client answering requests in memory and an
+// httptest server, both scripted with expectations that are asserted when the
+// test ends.
+//
+//	c := httpclienttest.NewClient(t)
+//	c.Expect("GET", "/users/1").
+//		WithHeader("Accept", "application/json").
+//		RespondJSON(200, map[string]interface{}{"id": 1})
+//	svc := users.NewService(c)
+package httpclienttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gadventures/httpclient"
+)
+
+// Server is an httptest.Server serving the expectations of its Mock. Use it
+// when the code under test dials a URL rather than taking a Client.
+type Server struct {
+	*httptest.Server
+	*Mock
+}
+
+// NewServer starts a Server, which is closed and whose expectations are
+// asserted at t.Cleanup
+func NewServer(t testing.TB) *Server {
+	m := NewMock(t)
+	s := &Server{httptest.NewServer(m), m}
+	t.Cleanup(func() {
+		s.Close()
+		m.AssertExpectations()
+	})
+	return s
+}
+
+// Client is an httpclient.Client whose requests, to any host, are answered in
+// memory by its Mock
+type Client struct {
+	httpclient.Client
+	*Mock
+}
+
+// NewClient returns a Client created with the given options, which is closed
+// and whose expectations are asserted at t.Cleanup
+func NewClient(t testing.TB, opts ...httpclient.Option) *Client {
+	t.Helper()
+	m := NewMock(t)
+	rt := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		res := rec.Result()
+		res.Request = req
+		return res, nil
+	})
+	opts = append(opts[:len(opts):len(opts)], httpclient.WithRoundTripper(rt))
+	c, err := httpclient.New(opts...)
+	if err != nil {
+		t.Fatalf("httpclienttest: creating the client: %v", err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		m.AssertExpectations()
+	})
+	return &Client{c, m}
+}
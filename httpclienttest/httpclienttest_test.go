@@ -0,0 +1,143 @@
+package httpclienttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// fakeT records the failures and cleanups of a test
+type fakeT struct {
+	testing.TB
+	mu       sync.Mutex
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) cleanup() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+// readBody is a ResponseHandler returning the status and body
+func readBody(status *int, body *string) httpclient.ResponseHandler {
+	return func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(res.Body)
+		*status, *body = res.StatusCode, string(b)
+		return err
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := NewClient(t, httpclient.Headers(http.Header{"Accept": {"application/json"}}))
+	c.Expect(http.MethodGet, "/users/1").
+		WithHeader("Accept", "application/json").
+		WithQuery("fields", "name").
+		RespondJSON(http.StatusOK, map[string]interface{}{"id": 1, "name": "bob"})
+	c.Expect(http.MethodPost, "/users").
+		WithJSON(map[string]interface{}{"name": "alice"}).
+		Respond(http.StatusServiceUnavailable, "").
+		Respond(http.StatusCreated, `{"id":2}`).
+		WithResponseHeader("Location", "/users/2")
+
+	ctx := context.Background()
+	var status int
+	var body string
+	if err := c.Get(ctx, readBody(&status, &body), "https://api.example.com/users/1?fields=name"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if status != http.StatusOK || body != `{"id":1,"name":"bob"}` {
+		t.Errorf("expected the user, got %d %s", status, body)
+	}
+
+	for _, expected := range []int{http.StatusServiceUnavailable, http.StatusCreated, http.StatusCreated} {
+		err := c.Post(ctx, readBody(&status, &body), "https://api.example.com/users", strings.NewReader(`{ "name": "alice" }`))
+		if err != nil {
+			t.Fatalf("trouble when making POST request: %v", err)
+		}
+		if status != expected {
+			t.Errorf("expected %d, got %d", expected, status)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(t)
+	s.Expect(http.MethodGet, "/slow").Delay(time.Second)
+	s.Expect(http.MethodGet, "/once").Respond(http.StatusOK, "once").Times(1)
+
+	c, err := httpclient.New()
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Get(ctx, httpclient.NoopResponseHandler, s.URL+"/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	var status int
+	var body string
+	if err := c.Get(context.Background(), readBody(&status, &body), s.URL+"/once"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if body != "once" {
+		t.Errorf("expected once, got %s", body)
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	ft := new(fakeT)
+	c := NewClient(ft)
+	c.Expect(http.MethodGet, "/called").Times(2)
+	c.Expect(http.MethodGet, "/never")
+	c.Expect(http.MethodGet, "/sequence").Respond(http.StatusOK, "1").Respond(http.StatusOK, "2")
+
+	ctx := context.Background()
+	var status int
+	var body string
+	for _, path := range []string{"/called", "/sequence", "/unexpected"} {
+		if err := c.Get(ctx, readBody(&status, &body), "http://example.com"+path); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+	}
+	if status != http.StatusNotImplemented {
+		t.Errorf("expected %d for the unexpected request, got %d", http.StatusNotImplemented, status)
+	}
+	ft.cleanup()
+
+	expected := []string{
+		"httpclienttest: unexpected request GET http://example.com/unexpected",
+		"httpclienttest: expected GET /called to be called 2 times, got 1",
+		"httpclienttest: expected GET /never to be called at least 1 times, got 0",
+		"httpclienttest: expected GET /sequence to be called at least 2 times, got 1",
+	}
+	if strings.Join(ft.errors, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected failures:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(ft.errors, "\n"))
+	}
+}
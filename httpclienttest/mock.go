@@ -0,0 +1,293 @@
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Mock is an http.Handler serving the scripted responses of its expectations.
+// Requests matching none of them fail the test and get a 501 Not Implemented.
+type Mock struct {
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Expectation
+}
+
+// NewMock returns a Mock failing t on unexpected requests. Use
+// AssertExpectations to check that all expectations were met, NewServer and
+// NewClient do it at t.Cleanup.
+func NewMock(t testing.TB) *Mock {
+	return &Mock{t: t}
+}
+
+// Expect adds an expectation of requests with the given method and path. It
+// responds with 200 OK and no body until told otherwise.
+func (m *Mock) Expect(method, path string) *Expectation {
+	e := &Expectation{
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(map[string]string),
+		times:  -1,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// AssertExpectations fails the test for every expectation that was not called
+// as many times as expected
+func (m *Mock) AssertExpectations() {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expectations {
+		switch {
+		case e.times >= 0 && e.calls != e.times:
+			m.t.Errorf("httpclienttest: expected %s to be called %d times, got %d", e, e.times, e.calls)
+		case e.times < 0 && e.calls < e.minCalls():
+			m.t.Errorf("httpclienttest: expected %s to be called at least %d times, got %d", e, e.minCalls(), e.calls)
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			m.t.Errorf("httpclienttest: reading the body of %s %s: %v", r.Method, r.URL, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res, ok := m.match(r, body)
+	if !ok {
+		m.t.Errorf("httpclienttest: unexpected request %s %s", r.Method, r.URL)
+		http.Error(w, "unexpected request", http.StatusNotImplemented)
+		return
+	}
+	if res.delay > 0 {
+		select {
+		case <-time.After(res.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if res.handler != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		res.handler(w, r)
+		return
+	}
+	for k, vs := range res.header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(res.status)
+	w.Write(res.body)
+}
+
+// match returns the next response of the first expectation matching r that
+// is not exhausted
+func (m *Mock) match(r *http.Request, body []byte) (*response, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expectations {
+		if e.times >= 0 && e.calls >= e.times {
+			continue
+		}
+		if e.matches(r, body) {
+			res := e.next()
+			e.calls++
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+// Expectation is a request the Mock expects, along with the responses it gets.
+// Its methods return the Expectation so that calls can be chained.
+type Expectation struct {
+	method string
+	path   string
+	header http.Header
+	query  map[string]string
+	body   func([]byte) bool
+
+	responses []*response
+	times     int // -1 if not set
+	calls     int
+}
+
+type response struct {
+	status  int
+	header  http.Header
+	body    []byte
+	delay   time.Duration
+	handler http.HandlerFunc
+}
+
+func (e *Expectation) String() string {
+	return e.method + " " + e.path
+}
+
+// WithHeader makes the expectation match requests having the header set to
+// the given value
+func (e *Expectation) WithHeader(name, value string) *Expectation {
+	e.header.Add(name, value)
+	return e
+}
+
+// WithQuery makes the expectation match requests having the query parameter
+// set to the given value
+func (e *Expectation) WithQuery(name, value string) *Expectation {
+	e.query[name] = value
+	return e
+}
+
+// WithBody makes the expectation match requests with the given body
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body = func(b []byte) bool {
+		return string(b) == body
+	}
+	return e
+}
+
+// WithJSON makes the expectation match requests with a JSON body equal to v
+// once marshalled, regardless of the formatting and order of fields
+func (e *Expectation) WithJSON(v interface{}) *Expectation {
+	expected, err := normalizeJSON(v)
+	e.body = func(b []byte) bool {
+		var actual interface{}
+		if err != nil || json.Unmarshal(b, &actual) != nil {
+			return false
+		}
+		return reflect.DeepEqual(expected, actual)
+	}
+	return e
+}
+
+// Respond adds a response to the sequence of responses of the expectation.
+// The nth matching request gets the nth response, the last one is repeated.
+func (e *Expectation) Respond(status int, body string) *Expectation {
+	e.responses = append(e.responses, &response{
+		status: status,
+		header: make(http.Header),
+		body:   []byte(body),
+	})
+	return e
+}
+
+// RespondJSON adds a response with v marshalled as JSON body to the sequence
+// of responses of the expectation
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpclienttest: marshalling response of %s: %v", e, err))
+	}
+	e.Respond(status, string(b))
+	return e.WithResponseHeader("Content-Type", "application/json")
+}
+
+// RespondWith adds a response written by h to the sequence of responses of
+// the expectation
+func (e *Expectation) RespondWith(h http.HandlerFunc) *Expectation {
+	e.responses = append(e.responses, &response{handler: h})
+	return e
+}
+
+// WithResponseHeader sets a header of the last response added
+func (e *Expectation) WithResponseHeader(name, value string) *Expectation {
+	e.last().header.Set(name, value)
+	return e
+}
+
+// Delay holds the last response added for d, or until the request is
+// cancelled, e.g. to trigger timeouts
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.last().delay = d
+	return e
+}
+
+// Times makes the expectation match exactly n requests. By default it
+// matches any number of requests, but at least one per response added.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// last returns the last response added, adding a 200 OK if there is none
+func (e *Expectation) last() *response {
+	if len(e.responses) == 0 {
+		e.Respond(http.StatusOK, "")
+	}
+	return e.responses[len(e.responses)-1]
+}
+
+// next returns the response to the current call
+func (e *Expectation) next() *response {
+	if len(e.responses) == 0 {
+		return e.last()
+	}
+	if e.calls < len(e.responses) {
+		return e.responses[e.calls]
+	}
+	return e.responses[len(e.responses)-1]
+}
+
+func (e *Expectation) minCalls() int {
+	if len(e.responses) > 1 {
+		return len(e.responses)
+	}
+	return 1
+}
+
+func (e *Expectation) matches(r *http.Request, body []byte) bool {
+	if r.Method != e.method || r.URL.Path != e.path {
+		return false
+	}
+	for name, values := range e.header {
+		actual := r.Header.Values(name)
+		for _, v := range values {
+			if !contains(actual, v) {
+				return false
+			}
+		}
+	}
+	query := r.URL.Query()
+	for name, v := range e.query {
+		if !contains(query[name], v) {
+			return false
+		}
+	}
+	return e.body == nil || e.body(body)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON returns v as the generic value it unmarshals to
+func normalizeJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
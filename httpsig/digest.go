@@ -0,0 +1,80 @@
+package httpsig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// contentDigest returns the Content-Digest header of content (RFC 9530)
+func contentDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha-256=" + byteSequence(sum[:])
+}
+
+// checkContentDigest verifies that the Content-Digest header matches content
+// for every supported algorithm, of which there must be one
+func checkContentDigest(header string, content []byte) error {
+	members, err := parseDictionary(header)
+	if err != nil {
+		return err
+	}
+	checked := false
+	for _, m := range members {
+		var sum []byte
+		switch m.key {
+		case "sha-256":
+			s := sha256.Sum256(content)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(content)
+			sum = s[:]
+		default:
+			continue
+		}
+		digest, err := parseByteSequence(m.value)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(digest, sum) != 1 {
+			return fmt.Errorf("httpsig: %s Content-Digest does not match the content", m.key)
+		}
+		checked = true
+	}
+	if !checked {
+		return fmt.Errorf("httpsig: no supported algorithm in Content-Digest %s", header)
+	}
+	return nil
+}
+
+// readBody reads body, replacing it with a reader of the same content. A body
+// larger than limit bytes, unless limit is zero, fails with
+// errContentTooLarge and is closed.
+func readBody(body *io.ReadCloser, limit int64) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	r := io.Reader(*body)
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	b, err := io.ReadAll(r)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(b)) > limit {
+		return nil, errContentTooLarge
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// errContentTooLarge is the error of content larger than the MaxContentSize
+// of a Verifier
+var errContentTooLarge = errors.New("httpsig: content too large to check its digest")
@@ -0,0 +1,170 @@
+// Package httpsig signs requests and verifies responses with HTTP Message
+// Signatures (RFC 9421), as httpclient.Middleware.
+//
+//	key, err := httpsig.NewKey("partner-key-1", privateKey)
+//	verify, err := httpsig.NewKey("partner-server", serverPublicKey)
+//	client, err := httpclient.New(httpclient.Use(
+//		httpsig.SignRequests(&httpsig.Signer{Key: key}),
+//		httpsig.VerifyResponses(&httpsig.Verifier{Keys: []httpsig.Key{verify}}),
+//	))
+//
+// Covered components are derived components such as @method, @target-uri or
+// @status, and lower case header field names. The components of a response
+// signature referring to its request have the req parameter, e.g.
+// "@method;req". The content-digest component is the Content-Digest header
+// (RFC 9530), which is computed when signing and checked against the content
+// when verifying.
+package httpsig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSignature is returned, possibly wrapped, when a message is not
+// signed or its signature cannot be verified
+var ErrInvalidSignature = errors.New("httpsig: invalid signature")
+
+// component is a covered component identifier
+type component struct {
+	name string
+	req  bool // the component of the request of a response
+}
+
+// parseComponent parses a component as given to Signer and Verifier, e.g.
+// "@method" or "@method;req"
+func parseComponent(s string) (component, error) {
+	name, params, _ := strings.Cut(s, ";")
+	c := component{name: name}
+	switch params {
+	case "":
+	case "req":
+		c.req = true
+	default:
+		return c, fmt.Errorf("httpsig: unsupported component parameters %s", s)
+	}
+	if name == "" || name != strings.ToLower(name) {
+		return c, fmt.Errorf("httpsig: invalid component %s", s)
+	}
+	return c, nil
+}
+
+func (c component) String() string {
+	s := strconv.Quote(c.name)
+	if c.req {
+		s += ";req"
+	}
+	return s
+}
+
+// message is the request or response whose components are signed
+type message struct {
+	req *http.Request
+	res *http.Response
+}
+
+// value returns the value of component c of the message (RFC 9421 section 2)
+func (m message) value(c component) (string, error) {
+	req, header := m.req, http.Header(nil)
+	switch {
+	case m.res != nil && c.req:
+		req = m.res.Request
+		if req == nil {
+			return "", fmt.Errorf("httpsig: no request for component %s", c)
+		}
+		header = req.Header
+	case m.res != nil:
+		req, header = nil, m.res.Header
+	case c.req:
+		return "", fmt.Errorf("httpsig: component %s of a request", c)
+	default:
+		header = req.Header
+	}
+
+	if c.name == "@status" {
+		if req != nil {
+			return "", fmt.Errorf("httpsig: component %s of a request", c)
+		}
+		return strconv.Itoa(m.res.StatusCode), nil
+	}
+	if strings.HasPrefix(c.name, "@") && req == nil {
+		return "", fmt.Errorf("httpsig: component %s of a response", c)
+	}
+	switch c.name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		u := *req.URL
+		u.Scheme, u.Host = scheme(req), authority(req)
+		return u.String(), nil
+	case "@authority":
+		return authority(req), nil
+	case "@scheme":
+		return scheme(req), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if p := req.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	case "host":
+		if req != nil {
+			return authority(req), nil
+		}
+	}
+	if strings.HasPrefix(c.name, "@") {
+		return "", fmt.Errorf("httpsig: unsupported component %s", c)
+	}
+	values := header.Values(c.name)
+	if len(values) == 0 {
+		return "", fmt.Errorf("httpsig: missing header for component %s", c)
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// authority returns the lower case host of req
+func authority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	return strings.ToLower(host)
+}
+
+// scheme returns the lower case scheme of req, which has none on servers
+func scheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// signatureBase returns the signature base of the message for the covered
+// components and serialized signature parameters (RFC 9421 section 2.5)
+func signatureBase(m message, components []component, params string) ([]byte, error) {
+	var b strings.Builder
+	for _, c := range components {
+		v, err := m.value(c)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("httpsig: invalid value of component %s", c)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", c, v)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,189 @@
+package httpsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Algorithms of RFC 9421 section 3.3, the alg parameter of signatures
+const (
+	AlgorithmHMACSHA256      = "hmac-sha256"
+	AlgorithmEd25519         = "ed25519"
+	AlgorithmECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgorithmRSAPSSSHA512    = "rsa-pss-sha512"
+)
+
+// Key signs and verifies signatures. Keys made of a public key only fail to
+// sign.
+type Key interface {
+	// ID is the keyid parameter of signatures
+	ID() string
+	// Algorithm is the alg parameter of signatures
+	Algorithm() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, sig []byte) error
+}
+
+// NewKey returns the Key of the given keyid for key, which is one of:
+//   - []byte, a shared secret for hmac-sha256
+//   - ed25519.PrivateKey or ed25519.PublicKey for ed25519
+//   - *ecdsa.PrivateKey or *ecdsa.PublicKey on P-256 for ecdsa-p256-sha256
+//   - *rsa.PrivateKey or *rsa.PublicKey for rsa-pss-sha512
+func NewKey(id string, key interface{}) (Key, error) {
+	switch k := key.(type) {
+	case []byte:
+		if len(k) == 0 {
+			return nil, errors.New("httpsig: empty HMAC secret")
+		}
+		return &hmacKey{id: id, secret: k}, nil
+	case ed25519.PrivateKey:
+		return &ed25519Key{id: id, private: k, public: k.Public().(ed25519.PublicKey)}, nil
+	case ed25519.PublicKey:
+		return &ed25519Key{id: id, public: k}, nil
+	case *ecdsa.PrivateKey:
+		if err := checkCurve(&k.PublicKey); err != nil {
+			return nil, err
+		}
+		return &ecdsaKey{id: id, private: k, public: &k.PublicKey}, nil
+	case *ecdsa.PublicKey:
+		if err := checkCurve(k); err != nil {
+			return nil, err
+		}
+		return &ecdsaKey{id: id, public: k}, nil
+	case *rsa.PrivateKey:
+		return &rsaPSSKey{id: id, private: k, public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &rsaPSSKey{id: id, public: k}, nil
+	case nil:
+		return nil, errors.New("httpsig: missing key")
+	}
+	return nil, fmt.Errorf("httpsig: unsupported key %T", key)
+}
+
+func checkCurve(k *ecdsa.PublicKey) error {
+	if k.Curve.Params().Name != "P-256" {
+		return fmt.Errorf("httpsig: unsupported curve %s", k.Curve.Params().Name)
+	}
+	return nil
+}
+
+var errPublicKey = errors.New("httpsig: cannot sign with a public key")
+
+type hmacKey struct {
+	id     string
+	secret []byte
+}
+
+func (k *hmacKey) ID() string        { return k.id }
+func (k *hmacKey) Algorithm() string { return AlgorithmHMACSHA256 }
+
+func (k *hmacKey) Sign(data []byte) ([]byte, error) {
+	m := hmac.New(sha256.New, k.secret)
+	m.Write(data)
+	return m.Sum(nil), nil
+}
+
+func (k *hmacKey) Verify(data, sig []byte) error {
+	expected, _ := k.Sign(data)
+	if !hmac.Equal(expected, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ed25519Key struct {
+	id      string
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+func (k *ed25519Key) ID() string        { return k.id }
+func (k *ed25519Key) Algorithm() string { return AlgorithmEd25519 }
+
+func (k *ed25519Key) Sign(data []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errPublicKey
+	}
+	return ed25519.Sign(k.private, data), nil
+}
+
+func (k *ed25519Key) Verify(data, sig []byte) error {
+	if !ed25519.Verify(k.public, data, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ecdsaKey struct {
+	id      string
+	private *ecdsa.PrivateKey
+	public  *ecdsa.PublicKey
+}
+
+func (k *ecdsaKey) ID() string        { return k.id }
+func (k *ecdsaKey) Algorithm() string { return AlgorithmECDSAP256SHA256 }
+
+func (k *ecdsaKey) Sign(data []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errPublicKey
+	}
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	// the fixed size concatenation of r and s, not ASN.1
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+func (k *ecdsaKey) Verify(data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	if len(sig) != 64 {
+		return ErrInvalidSignature
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(k.public, digest[:], r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// pssOptions are those of rsa-pss-sha512, a 64 bytes salt
+var pssOptions = &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512}
+
+type rsaPSSKey struct {
+	id      string
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+func (k *rsaPSSKey) ID() string        { return k.id }
+func (k *rsaPSSKey) Algorithm() string { return AlgorithmRSAPSSSHA512 }
+
+func (k *rsaPSSKey) Sign(data []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errPublicKey
+	}
+	digest := sha512.Sum512(data)
+	return rsa.SignPSS(rand.Reader, k.private, crypto.SHA512, digest[:], pssOptions)
+}
+
+func (k *rsaPSSKey) Verify(data, sig []byte) error {
+	digest := sha512.Sum512(data)
+	if rsa.VerifyPSS(k.public, crypto.SHA512, digest[:], sig, pssOptions) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
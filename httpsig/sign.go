@@ -0,0 +1,176 @@
+package httpsig
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// DefaultLabel is the label of the signatures of a Signer by default
+const DefaultLabel = "sig1"
+
+// DefaultComponents are the components covered by a Signer by default
+var DefaultComponents = []string{"@method", "@target-uri", "content-digest"}
+
+// Signer signs requests with its Key
+type Signer struct {
+	Key Key
+	// Label of the signature, DefaultLabel if empty. A signature of the same
+	// label already in the request is replaced.
+	Label string
+	// Components covered by the signature, DefaultComponents if nil. The
+	// content-digest component of a request without content nor
+	// Content-Digest header is left out, otherwise the Content-Digest header
+	// is set unless it is already.
+	Components []string
+	// Expires, if set, is how long the signature is valid
+	Expires time.Duration
+	// Nonce adds a random nonce to the signature
+	Nonce bool
+	// Tag, if set, is the tag parameter of the signature, naming the
+	// application of the signature
+	Tag string
+
+	now func() time.Time
+}
+
+// SignRequest adds the Signature-Input and Signature headers to req. The body
+// of req is read if the content-digest component is covered, and replaced if
+// it cannot be read again with GetBody.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if s.Key == nil {
+		return errors.New("httpsig: missing signing key")
+	}
+	names := s.Components
+	if names == nil {
+		names = DefaultComponents
+	}
+	var components []component
+	for _, name := range names {
+		c, err := parseComponent(name)
+		if err != nil {
+			return err
+		}
+		if c.name == "content-digest" && !c.req && req.Header.Get("Content-Digest") == "" {
+			ok, err := setContentDigest(req)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		components = append(components, c)
+	}
+	return s.sign(message{req: req}, req.Header, components)
+}
+
+// sign adds the signature of the components of m to its header h
+func (s *Signer) sign(m message, h http.Header, components []component) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	created := now()
+	p := sigParams{created: created.Unix(), keyID: s.Key.ID(), alg: s.Key.Algorithm(), tag: s.Tag}
+	if s.Expires > 0 {
+		p.expires = created.Add(s.Expires).Unix()
+	}
+	if s.Nonce {
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		p.nonce = base64.RawURLEncoding.EncodeToString(nonce)
+	}
+	params := p.serialize(components)
+	base, err := signatureBase(m, components, params)
+	if err != nil {
+		return err
+	}
+	sig, err := s.Key.Sign(base)
+	if err != nil {
+		return err
+	}
+
+	label := s.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+	if err := setMember(h, "Signature-Input", label, params); err != nil {
+		return err
+	}
+	return setMember(h, "Signature", label, byteSequence(sig))
+}
+
+// setContentDigest sets the Content-Digest header of req, it returns false if
+// req has no content
+func setContentDigest(req *http.Request) (bool, error) {
+	var content []byte
+	var err error
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		var body io.ReadCloser
+		if body, err = req.GetBody(); err != nil {
+			return false, err
+		}
+		content, err = io.ReadAll(body)
+		body.Close()
+	} else {
+		content, err = readBody(&req.Body, 0)
+		if content != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(content)), nil
+			}
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("httpsig: reading content: %w", err)
+	}
+	if content == nil {
+		return false, nil
+	}
+	req.Header.Set("Content-Digest", contentDigest(content))
+	return true, nil
+}
+
+// setMember sets the member of the dictionary header name with the given
+// label, keeping its other members
+func setMember(h http.Header, name, label, value string) error {
+	members, err := parseDictionary(strings.Join(h.Values(name), ", "))
+	if err != nil {
+		return err
+	}
+	var values []string
+	for _, m := range members {
+		if m.key != label {
+			values = append(values, m.key+"="+m.value)
+		}
+	}
+	h.Set(name, strings.Join(append(values, label+"="+value), ", "))
+	return nil
+}
+
+// SignRequests returns a Middleware signing the requests with s, see
+// Signer.SignRequest. Requests failing to be signed are not sent.
+func SignRequests(s *Signer) httpclient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			signed := req.Clone(req.Context())
+			if err := s.SignRequest(signed); err != nil {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, err
+			}
+			return next.RoundTrip(signed)
+		})
+	}
+}
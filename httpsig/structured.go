@@ -0,0 +1,180 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// member is a member of a dictionary structured field (RFC 8941 section 3.2)
+// such as Signature-Input, Signature or Content-Digest, whose value is kept
+// as sent
+type member struct {
+	key   string
+	value string
+}
+
+// parseDictionary splits a dictionary structured field into its members
+func parseDictionary(s string) ([]member, error) {
+	var members []member
+	for _, part := range splitOutside(s, ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || key == "" || key != strings.ToLower(key) {
+			return nil, fmt.Errorf("httpsig: invalid dictionary member %s", part)
+		}
+		members = append(members, member{key: key, value: strings.TrimSpace(value)})
+	}
+	return members, nil
+}
+
+// splitOutside splits s around sep, outside of quoted strings and inner lists
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	quoted, depth, start := false, 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// sigParams are the signature parameters of RFC 9421 section 2.3
+type sigParams struct {
+	created int64
+	expires int64
+	nonce   string
+	keyID   string
+	alg     string
+	tag     string
+}
+
+// parseSignatureInput parses the value of a Signature-Input member, an inner
+// list of components with the signature parameters
+func parseSignatureInput(s string) ([]component, sigParams, error) {
+	var p sigParams
+	end := strings.IndexByte(s, ')')
+	if !strings.HasPrefix(s, "(") || end < 0 {
+		return nil, p, fmt.Errorf("httpsig: invalid signature input %s", s)
+	}
+	var components []component
+	for _, item := range strings.Fields(s[1:end]) {
+		name, params, _ := strings.Cut(item, ";")
+		unquoted, err := unquote(name)
+		if err != nil {
+			return nil, p, err
+		}
+		if params != "" {
+			unquoted += ";" + params
+		}
+		c, err := parseComponent(unquoted)
+		if err != nil {
+			return nil, p, err
+		}
+		components = append(components, c)
+	}
+
+	for _, param := range splitOutside(s[end+1:], ';') {
+		if param == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(param, "=")
+		var err error
+		switch key {
+		case "created":
+			p.created, err = strconv.ParseInt(value, 10, 64)
+		case "expires":
+			p.expires, err = strconv.ParseInt(value, 10, 64)
+		case "nonce":
+			p.nonce, err = unquote(value)
+		case "keyid":
+			p.keyID, err = unquote(value)
+		case "alg":
+			p.alg, err = unquote(value)
+		case "tag":
+			p.tag, err = unquote(value)
+		}
+		if err != nil {
+			return nil, p, fmt.Errorf("httpsig: invalid signature parameter %s", param)
+		}
+	}
+	return components, p, nil
+}
+
+// serialize returns the signature parameters of the covered components as
+// sent in Signature-Input
+func (p sigParams) serialize(components []component) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, c := range components {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(c.String())
+	}
+	b.WriteByte(')')
+	if p.created != 0 {
+		fmt.Fprintf(&b, ";created=%d", p.created)
+	}
+	if p.expires != 0 {
+		fmt.Fprintf(&b, ";expires=%d", p.expires)
+	}
+	for _, sp := range []struct{ key, value string }{
+		{"nonce", p.nonce}, {"keyid", p.keyID}, {"alg", p.alg}, {"tag", p.tag},
+	} {
+		if sp.value != "" {
+			fmt.Fprintf(&b, ";%s=%s", sp.key, quote(sp.value))
+		}
+	}
+	return b.String()
+}
+
+// quote returns s as a structured field string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// unquote parses a structured field string
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("httpsig: invalid string %s", s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+// byteSequence returns b as a structured field byte sequence
+func byteSequence(b []byte) string {
+	return ":" + base64.StdEncoding.EncodeToString(b) + ":"
+}
+
+// parseByteSequence parses a structured field byte sequence
+func parseByteSequence(s string) ([]byte, error) {
+	if len(s) < 2 || s[0] != ':' || s[len(s)-1] != ':' {
+		return nil, errors.New("httpsig: invalid byte sequence")
+	}
+	return base64.StdEncoding.DecodeString(s[1 : len(s)-1])
+}
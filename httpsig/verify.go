@@ -0,0 +1,191 @@
+package httpsig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// DefaultMaxContentSize is the MaxContentSize of a Verifier by default
+const DefaultMaxContentSize = 10 << 20
+
+// Verifier verifies the signatures of messages with its Keys
+type Verifier struct {
+	// Keys, matched on the keyid parameter of signatures. A signature
+	// without keyid is verified with the only key, if there is one.
+	Keys []Key
+	// Label, if set, is the label of the signature to verify, otherwise the
+	// message needs one valid signature
+	Label string
+	// Required components the signature must cover, e.g. "@status" or
+	// "content-digest"
+	Required []string
+	// MaxAge, if set, is how old the signature may be according to its
+	// created parameter. Expired signatures are always rejected.
+	MaxAge time.Duration
+	// MaxContentSize is how many bytes of content are read to check the
+	// content-digest component, DefaultMaxContentSize if zero. Messages with
+	// more content fail verification.
+	MaxContentSize int64
+
+	now func() time.Time
+}
+
+// VerifyRequest verifies the signature of req, as received by a server. The
+// body of req is read, up to MaxContentSize bytes, if the content-digest
+// component is covered.
+func (v *Verifier) VerifyRequest(req *http.Request) error {
+	return v.verify(message{req: req}, req.Header, func() ([]byte, error) {
+		return readBody(&req.Body, v.maxContentSize())
+	})
+}
+
+// VerifyResponse verifies the signature of res. The body of res is read, up
+// to MaxContentSize bytes, if the content-digest component is covered.
+func (v *Verifier) VerifyResponse(res *http.Response) error {
+	return v.verify(message{res: res}, res.Header, func() ([]byte, error) {
+		return readBody(&res.Body, v.maxContentSize())
+	})
+}
+
+func (v *Verifier) maxContentSize() int64 {
+	if v.MaxContentSize > 0 {
+		return v.MaxContentSize
+	}
+	return DefaultMaxContentSize
+}
+
+func (v *Verifier) verify(m message, h http.Header, content func() ([]byte, error)) error {
+	inputs, err := parseDictionary(strings.Join(h.Values("Signature-Input"), ", "))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	sigs, err := parseDictionary(strings.Join(h.Values("Signature"), ", "))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	var lastErr error
+	for _, in := range inputs {
+		if v.Label != "" && in.key != v.Label {
+			continue
+		}
+		var sig string
+		for _, s := range sigs {
+			if s.key == in.key {
+				sig = s.value
+			}
+		}
+		if lastErr = v.verifySignature(m, in, sig, content); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		return fmt.Errorf("%w: message not signed", ErrInvalidSignature)
+	}
+	return lastErr
+}
+
+// verifySignature verifies the signature sig of the Signature-Input member in
+func (v *Verifier) verifySignature(m message, in member, sig string, content func() ([]byte, error)) error {
+	invalid := func(format string, args ...interface{}) error {
+		msg := strings.TrimPrefix(fmt.Sprintf(format, args...), "httpsig: ")
+		return fmt.Errorf("%w: signature %s: %s", ErrInvalidSignature, in.key, msg)
+	}
+	components, p, err := parseSignatureInput(in.value)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	for _, name := range v.Required {
+		r, err := parseComponent(name)
+		if err != nil {
+			return err
+		}
+		covered := false
+		for _, c := range components {
+			covered = covered || c == r
+		}
+		if !covered {
+			return invalid("component %s not covered", r)
+		}
+	}
+
+	var key Key
+	for _, k := range v.Keys {
+		if k.ID() == p.keyID || (p.keyID == "" && len(v.Keys) == 1) {
+			key = k
+		}
+	}
+	if key == nil {
+		return invalid("unknown key %q", p.keyID)
+	}
+	if p.alg != "" && p.alg != key.Algorithm() {
+		return invalid("algorithm %s does not match the key", p.alg)
+	}
+
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	if p.expires != 0 && now().Unix() > p.expires {
+		return invalid("expired")
+	}
+	if v.MaxAge > 0 && (p.created == 0 || now().Sub(time.Unix(p.created, 0)) > v.MaxAge) {
+		return invalid("older than %s", v.MaxAge)
+	}
+
+	if sig == "" {
+		return invalid("missing Signature")
+	}
+	signature, err := parseByteSequence(sig)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	base, err := signatureBase(m, components, in.value)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	if key.Verify(base, signature) != nil {
+		return invalid("does not match")
+	}
+
+	for _, c := range components {
+		if c.name != "content-digest" || c.req {
+			continue
+		}
+		b, err := content()
+		if errors.Is(err, errContentTooLarge) {
+			return invalid("content larger than %d bytes", v.maxContentSize())
+		}
+		if err != nil {
+			return fmt.Errorf("httpsig: reading content: %w", err)
+		}
+		digest, _ := m.value(c)
+		if err := checkContentDigest(digest, b); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	return nil
+}
+
+// VerifyResponses returns a Middleware verifying the responses with v, see
+// Verifier.VerifyResponse. A response failing verification is closed and
+// its error, wrapping ErrInvalidSignature, returned instead.
+func VerifyResponses(v *Verifier) httpclient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			res, err := next.RoundTrip(req)
+			if err != nil {
+				return res, err
+			}
+			if err := v.VerifyResponse(res); err != nil {
+				res.Body.Close()
+				return nil, err
+			}
+			return res, nil
+		})
+	}
+}
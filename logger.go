@@ -1 +1,84 @@
 package httpclient
+
+import (
+	"log"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Messages of the structured log events, see WithSlogger
+const (
+	LogClientInitialized = "client initialized"
+	LogClientClosed      = "client closed"
+	LogRequestStart      = "request start"
+	LogRequestEnd        = "request end"
+	LogDial              = "dial"
+	LogDialFailed        = "dial failed"
+	LogConnAcquired      = "conn acquired"
+	LogConnClosed        = "conn closed"
+	LogCurl              = "curl"
+	LogRedirect          = "redirect"
+	LogRetry             = "retry"
+	LogCertReloadFailed  = "cert reload failed"
+)
+
+// Attribute keys of the structured log events, see WithSlogger
+const (
+	LogKeyAddr         = "addr"
+	LogKeyAttempt      = "attempt"
+	LogKeyBytesRead    = "bytes_read"
+	LogKeyBytesWritten = "bytes_written"
+	LogKeyConnID       = "conn_id"
+	LogKeyCurl         = "curl"
+	LogKeyDuration     = "duration"
+	LogKeyError        = "error"
+	LogKeyIdleTime     = "idle_time"
+	LogKeyLifetime     = "lifetime"
+	LogKeyMethod       = "method"
+	LogKeyNetwork      = "network"
+	LogKeyNotAfter     = "not_after"
+	LogKeyReason       = "reason"
+	LogKeyRedirects    = "redirects"
+	LogKeyRequests     = "requests"
+	LogKeyReused       = "reused"
+	LogKeyStatus       = "status"
+	LogKeyURL          = "url"
+)
+
+// newLegacyLogger returns a *slog.Logger writing every event, including
+// debug ones, as text to l. It is used when no logger was given with
+// WithSlogger, keeping the Logger and LogPrefix options working.
+func newLegacyLogger(l *log.Logger) *slog.Logger {
+	return slog.New(slog.NewTextHandler(legacyWriter{l}, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch a.Key {
+			case slog.TimeKey:
+				// the *log.Logger adds its own timestamp
+				return slog.Attr{}
+			case slog.SourceKey:
+				if src, ok := a.Value.Any().(*slog.Source); ok {
+					return slog.String(slog.SourceKey, filepath.Base(src.File)+":"+strconv.Itoa(src.Line))
+				}
+			}
+			return a
+		},
+	}))
+}
+
+// legacyWriter passes the lines written by the slog.TextHandler on to a
+// *log.Logger, which adds the prefix and timestamp
+type legacyWriter struct {
+	log *log.Logger
+}
+
+func (w legacyWriter) Write(p []byte) (int, error) {
+	w.log.Print(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithSlogger(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+		}
+	}))
+	defer s.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c, err := New(WithSlogger(logger))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	if err := c.Get(context.Background(), NoopResponseHandler, s.URL+"/redirect"); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	c.Close()
+
+	events := make(map[string][]map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		msg := ev[slog.MessageKey].(string)
+		events[msg] = append(events[msg], ev)
+	}
+
+	tests := []struct {
+		msg   string
+		count int
+		level string
+		attrs map[string]interface{}
+	}{
+		{LogClientInitialized, 1, "DEBUG", nil},
+		{LogRequestStart, 1, "DEBUG", map[string]interface{}{LogKeyMethod: "GET", LogKeyURL: s.URL + "/redirect"}},
+		{LogDial, 1, "DEBUG", map[string]interface{}{LogKeyConnID: 1.0}},
+		{LogConnAcquired, 2, "DEBUG", map[string]interface{}{LogKeyConnID: 1.0}},
+		{LogRedirect, 1, "DEBUG", map[string]interface{}{LogKeyRedirects: 1.0, LogKeyStatus: 302.0}},
+		{LogRequestEnd, 1, "INFO", map[string]interface{}{LogKeyStatus: 200.0, LogKeyReused: true}},
+		{LogConnClosed, 1, "DEBUG", map[string]interface{}{LogKeyReason: string(CloseReasonClient), LogKeyRequests: 2.0}},
+		{LogClientClosed, 1, "INFO", nil},
+	}
+	for _, tc := range tests {
+		evs := events[tc.msg]
+		if len(evs) != tc.count {
+			t.Errorf("expected %d %q events, got %d: %v", tc.count, tc.msg, len(evs), evs)
+			continue
+		}
+		if evs[0][slog.LevelKey] != tc.level {
+			t.Errorf("expected %q at level %s, got %v", tc.msg, tc.level, evs[0][slog.LevelKey])
+		}
+		for k, v := range tc.attrs {
+			if evs[0][k] != v {
+				t.Errorf("expected %q to have %s=%v, got %v", tc.msg, k, v, evs[0][k])
+			}
+		}
+	}
+
+	if _, err := New(WithSlogger(nil)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
+
+func TestLegacyLogger(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+
+	var buf bytes.Buffer
+	c, err := New(Logger(&buf), LogPrefix("legacy: "))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	if err := c.Get(context.Background(), NoopResponseHandler, s.URL); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	var found bool
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, `msg="request end"`) {
+			found = true
+			if !strings.HasPrefix(line, "legacy: ") || !strings.Contains(line, "status=404") ||
+				!strings.Contains(line, "source=requests.go:") {
+				t.Errorf("unexpected legacy log line %q", line)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("missing request end event in %q", buf.String())
+	}
+}
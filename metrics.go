@@ -0,0 +1,144 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// MetricsRecorder receives the measurements taken by a Client, see the
+// WithMetricsRecorder option. Implementations must be safe for concurrent use
+// and should not block.
+type MetricsRecorder interface {
+	// RequestStarted is called before a request is handed to the Transport
+	RequestStarted(method, host string)
+
+	// RequestDone is called once the response headers were received or the
+	// request failed. Every redirect is a request of its own.
+	RequestDone(m RequestMetrics)
+
+	// Dialed is called after every attempt to dial a connection
+	Dialed(m DialMetrics)
+
+	// TLSHandshakeDone is called after every TLS handshake
+	TLSHandshakeDone(m TLSHandshakeMetrics)
+}
+
+// StatsRecorder is optionally implemented by a MetricsRecorder that exports
+// the connection pool Stats. RegisterStats is called by New with the Stats
+// method of every Client the recorder was passed to, the returned function is
+// called by Close to stop exporting them.
+type StatsRecorder interface {
+	RegisterStats(stats func() Stats) (unregister func())
+}
+
+// RequestMetrics describes a single request made by the Client
+type RequestMetrics struct {
+	Method string
+	Host   string
+	// StatusCode of the response, 0 if the request failed
+	StatusCode int
+	// Duration until the response headers were received
+	Duration time.Duration
+	// RequestSize and ResponseSize are the body sizes as given by the
+	// Content-Length, -1 if unknown
+	RequestSize  int64
+	ResponseSize int64
+	// Reused is true if the request was sent on a connection from the pool
+	Reused bool
+	Err    error
+}
+
+// DialMetrics describes a single dial attempt made by the Client
+type DialMetrics struct {
+	Network  string
+	Addr     string
+	ConnID   int64
+	Duration time.Duration
+	Err      error
+}
+
+// TLSHandshakeMetrics describes a single TLS handshake made by the Client
+type TLSHandshakeMetrics struct {
+	Host     string
+	Duration time.Duration
+	Version  uint16 // the negotiated TLS version, 0 if the handshake failed
+	Err      error
+}
+
+// multiRecorder fans the measurements out to several MetricsRecorders
+type multiRecorder []MetricsRecorder
+
+func (m multiRecorder) RequestStarted(method, host string) {
+	for _, r := range m {
+		r.RequestStarted(method, host)
+	}
+}
+
+func (m multiRecorder) RequestDone(rm RequestMetrics) {
+	for _, r := range m {
+		r.RequestDone(rm)
+	}
+}
+
+func (m multiRecorder) Dialed(dm DialMetrics) {
+	for _, r := range m {
+		r.Dialed(dm)
+	}
+}
+
+func (m multiRecorder) TLSHandshakeDone(tm TLSHandshakeMetrics) {
+	for _, r := range m {
+		r.TLSHandshakeDone(tm)
+	}
+}
+
+// metricsTransport is the http.RoundTripper taking the request measurements
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics MetricsRecorder
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	m := RequestMetrics{
+		Method:       req.Method,
+		Host:         host,
+		RequestSize:  req.ContentLength,
+		ResponseSize: -1,
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		m.RequestSize = 0
+	}
+	var tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			m.Reused = info.Reused
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.metrics.TLSHandshakeDone(TLSHandshakeMetrics{
+				Host:     host,
+				Duration: time.Since(tlsStart),
+				Version:  state.Version,
+				Err:      err,
+			})
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	t.metrics.RequestStarted(m.Method, host)
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	m.Duration = time.Since(start)
+	m.Err = err
+	if res != nil {
+		m.StatusCode = res.StatusCode
+		m.ResponseSize = res.ContentLength
+	}
+	t.metrics.RequestDone(m)
+	return res, err
+}
@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// instrumentation scope of the OpenTelemetry instruments
+const instrumentationName = "github.com/gadventures/httpclient"
+
+// otelMetrics is the MetricsRecorder behind the WithMetrics option. It emits
+// the OpenTelemetry HTTP client semantic convention metrics, plus dial, TLS
+// and connection pool instruments of its own.
+type otelMetrics struct {
+	requestDuration   metric.Float64Histogram
+	requestBodySize   metric.Int64Histogram
+	responseBodySize  metric.Int64Histogram
+	dialDuration      metric.Float64Histogram
+	handshakeDuration metric.Float64Histogram
+	// connection pool instruments, observed once registered with
+	// RegisterStats
+	meter       metric.Meter
+	openConns   metric.Int64ObservableUpDownCounter
+	closedConns metric.Int64ObservableCounter
+}
+
+// ensure interface implementations
+var _ StatsRecorder = &otelMetrics{}
+
+func newOtelMetrics(mp metric.MeterProvider) (*otelMetrics, error) {
+	meter := mp.Meter(instrumentationName)
+	m := &otelMetrics{meter: meter}
+	var err error
+	if m.requestDuration, err = meter.Float64Histogram(
+		"http.client.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP client requests."),
+	); err != nil {
+		return nil, err
+	}
+	if m.requestBodySize, err = meter.Int64Histogram(
+		"http.client.request.body.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP client request bodies."),
+	); err != nil {
+		return nil, err
+	}
+	if m.responseBodySize, err = meter.Int64Histogram(
+		"http.client.response.body.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP client response bodies."),
+	); err != nil {
+		return nil, err
+	}
+	if m.dialDuration, err = meter.Float64Histogram(
+		"httpclient.dial.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of dialing connections."),
+	); err != nil {
+		return nil, err
+	}
+	if m.handshakeDuration, err = meter.Float64Histogram(
+		"httpclient.tls.handshake.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of TLS handshakes."),
+	); err != nil {
+		return nil, err
+	}
+
+	// connection pool instruments are observed from the client's Stats
+	if m.openConns, err = meter.Int64ObservableUpDownCounter(
+		"http.client.open_connections",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("Number of outbound HTTP connections that are currently active or idle."),
+	); err != nil {
+		return nil, err
+	}
+	if m.closedConns, err = meter.Int64ObservableCounter(
+		"httpclient.connection.closed",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("Number of closed outbound HTTP connections."),
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegisterStats implements StatsRecorder. It is called by New once the client
+// is configured, so that a client failing to be created is not observed.
+func (m *otelMetrics) RegisterStats(stats func() Stats) func() {
+	openConns, closedConns := m.openConns, m.closedConns
+	registration, err := m.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for addr, hs := range stats().Hosts {
+			attrs := addrAttributes(addr)
+			attrs = attrs[:len(attrs):len(attrs)] // appends below must copy
+			o.ObserveInt64(openConns, hs.Active, metric.WithAttributes(
+				append(attrs, attribute.String("http.connection.state", "active"))...))
+			o.ObserveInt64(openConns, hs.Idle, metric.WithAttributes(
+				append(attrs, attribute.String("http.connection.state", "idle"))...))
+			o.ObserveInt64(closedConns, hs.Closed, metric.WithAttributes(attrs...))
+		}
+		return nil
+	}, openConns, closedConns)
+	if err != nil {
+		otel.Handle(err)
+		return func() {}
+	}
+	return func() {
+		if err := registration.Unregister(); err != nil {
+			otel.Handle(err)
+		}
+	}
+}
+
+// RequestStarted implements MetricsRecorder
+func (m *otelMetrics) RequestStarted(method, host string) {}
+
+// RequestDone implements MetricsRecorder
+func (m *otelMetrics) RequestDone(rm RequestMetrics) {
+	attrs := append(addrAttributes(rm.Host), attribute.String("http.request.method", rm.Method))
+	if rm.StatusCode > 0 {
+		attrs = append(attrs, attribute.Int("http.response.status_code", rm.StatusCode))
+	}
+	switch {
+	case rm.Err != nil:
+		attrs = append(attrs, attribute.String("error.type", errorType(rm.Err)))
+	case rm.StatusCode >= 400:
+		attrs = append(attrs, attribute.String("error.type", strconv.Itoa(rm.StatusCode)))
+	}
+	opt := metric.WithAttributes(attrs...)
+	ctx := context.Background()
+	m.requestDuration.Record(ctx, rm.Duration.Seconds(), opt)
+	if rm.RequestSize >= 0 {
+		m.requestBodySize.Record(ctx, rm.RequestSize, opt)
+	}
+	if rm.ResponseSize >= 0 {
+		m.responseBodySize.Record(ctx, rm.ResponseSize, opt)
+	}
+}
+
+// Dialed implements MetricsRecorder
+func (m *otelMetrics) Dialed(dm DialMetrics) {
+	attrs := append(addrAttributes(dm.Addr), attribute.String("network.transport", dm.Network))
+	if dm.Err != nil {
+		attrs = append(attrs, attribute.String("error.type", errorType(dm.Err)))
+	}
+	m.dialDuration.Record(context.Background(), dm.Duration.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// TLSHandshakeDone implements MetricsRecorder
+func (m *otelMetrics) TLSHandshakeDone(tm TLSHandshakeMetrics) {
+	attrs := addrAttributes(tm.Host)
+	if tm.Err != nil {
+		attrs = append(attrs, attribute.String("error.type", errorType(tm.Err)))
+	}
+	m.handshakeDuration.Record(context.Background(), tm.Duration.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// addrAttributes returns the server.address and server.port attributes of a
+// host with optional port
+func addrAttributes(hostport string) []attribute.KeyValue {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return []attribute.KeyValue{attribute.String("server.address", hostport)}
+	}
+	attrs := []attribute.KeyValue{attribute.String("server.address", host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attribute.Int("server.port", p))
+	}
+	return attrs
+}
+
+// errorType maps err to the low-cardinality error.type attribute value
+func errorType(err error) string {
+	var (
+		netErr     net.Error
+		dnsErr     *net.DNSError
+		recordErr  tls.RecordHeaderError
+		alertErr   tls.AlertError
+		verifyErr  *tls.CertificateVerificationError
+		unknownErr x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		certErr    x509.CertificateInvalidError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection_reset"
+	case errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &verifyErr),
+		errors.As(err, &unknownErr), errors.As(err, &hostErr), errors.As(err, &certErr):
+		return "tls"
+	}
+	return "_OTHER"
+}
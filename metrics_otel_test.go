@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestWithMetrics(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown meter provider: %v", err)
+		}
+	})
+
+	c, err := New(WithMetrics(provider))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if err := c.Get(context.Background(), NoopResponseHandler, s.URL); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != instrumentationName {
+			t.Errorf("unexpected instrumentation scope %q", sm.Scope.Name)
+		}
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+
+	for _, name := range []string{
+		"http.client.request.duration",
+		"http.client.request.body.size",
+		"http.client.response.body.size",
+		"httpclient.dial.duration",
+	} {
+		var count uint64
+		switch data := got[name].(type) {
+		case metricdata.Histogram[float64]:
+			for _, dp := range data.DataPoints {
+				count += dp.Count
+			}
+		case metricdata.Histogram[int64]:
+			for _, dp := range data.DataPoints {
+				count += dp.Count
+			}
+		default:
+			t.Errorf("missing histogram %s, got %T", name, data)
+			continue
+		}
+		expected := uint64(2)
+		if name == "httpclient.dial.duration" {
+			expected = 1
+		}
+		if count != expected {
+			t.Errorf("expected %d measurements of %s, got %d", expected, name, count)
+		}
+	}
+
+	open, ok := got["http.client.open_connections"].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("missing http.client.open_connections, got %T", got["http.client.open_connections"])
+	}
+	states := make(map[string]int64)
+	for _, dp := range open.DataPoints {
+		state, _ := dp.Attributes.Value("http.connection.state")
+		states[state.AsString()] = dp.Value
+	}
+	if states["idle"] != 1 || states["active"] != 0 {
+		t.Errorf("expected a single idle connection, got %v", states)
+	}
+
+	// the connection pool of a closed client is no longer observed
+	var observed int
+	m, err := newOtelMetrics(provider)
+	if err != nil {
+		t.Fatalf("trouble when creating the metrics: %v", err)
+	}
+	unregister := m.RegisterStats(func() Stats {
+		observed++
+		return Stats{}
+	})
+	unregister()
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	if observed != 0 {
+		t.Errorf("expected the unregistered callback not to be called, got %d calls", observed)
+	}
+
+	if _, err := New(WithMetrics(nil)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{context.Canceled, "canceled"},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), "timeout"},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, "dns"},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, "connection_refused"},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, "connection_reset"},
+		{&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, "tls"},
+		{errors.New("boom"), "_OTHER"},
+	}
+	for _, tc := range tests {
+		if got := errorType(tc.err); got != tc.expected {
+			t.Errorf("expected %s for %v, got %s", tc.expected, tc.err, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRecorder keeps all measurements passed to it
+type testRecorder struct {
+	mu       sync.Mutex
+	started  int
+	requests []RequestMetrics
+	dials    []DialMetrics
+}
+
+func (r *testRecorder) RequestStarted(method, host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started++
+}
+
+func (r *testRecorder) RequestDone(m RequestMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, m)
+}
+
+func (r *testRecorder) Dialed(m DialMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dials = append(r.dials, m)
+}
+
+func (r *testRecorder) TLSHandshakeDone(m TLSHandshakeMetrics) {}
+
+func TestWithMetricsRecorder(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	rec := new(testRecorder)
+	c, err := New(WithMetricsRecorder(rec))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.Get(ctx, NoopResponseHandler, s.URL); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if err := c.Post(ctx, NoopResponseHandler, s.URL, strings.NewReader("foo")); err != nil {
+		t.Fatalf("trouble when making POST request: %v", err)
+	}
+
+	if rec.started != 2 || len(rec.requests) != 2 {
+		t.Fatalf("expected 2 requests to be recorded, got %d started and %+v", rec.started, rec.requests)
+	}
+	get, post := rec.requests[0], rec.requests[1]
+	if get.Method != "GET" || get.StatusCode != 200 || get.Reused || get.RequestSize != 0 || get.ResponseSize != 5 {
+		t.Errorf("unexpected GET metrics: %+v", get)
+	}
+	if post.Method != "POST" || !post.Reused || post.RequestSize != 3 {
+		t.Errorf("unexpected POST metrics: %+v", post)
+	}
+	if len(rec.dials) != 1 || rec.dials[0].ConnID != 1 || rec.dials[0].Err != nil {
+		t.Errorf("expected a single successful dial, got %+v", rec.dials)
+	}
+
+	if _, err := New(WithMetricsRecorder(nil)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
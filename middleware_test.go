@@ -0,0 +1,63 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUse(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen", strings.Join(r.Header.Values("X-Middleware"), ","))
+	}))
+	defer s.Close()
+
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" in")
+				req = req.Clone(req.Context())
+				req.Header.Add("X-Middleware", name)
+				res, err := next.RoundTrip(req)
+				calls = append(calls, name+" out")
+				return res, err
+			})
+		}
+	}
+	c, err := New(Use(middleware("a"), middleware("b")), Use(middleware("c")))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	var seen string
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		seen = res.Header.Get("X-Seen")
+		return nil
+	}
+	if err := c.Get(context.Background(), rh, s.URL); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+
+	expected := "a in,b in,c in,c out,b out,a out"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Errorf("expected calls %s, got %s", expected, got)
+	}
+	if seen != "a,b,c" {
+		t.Errorf("expected the server to see the headers of a,b,c, got %s", seen)
+	}
+	// the client's own Transport is still used underneath
+	if dials := c.Stats().Dials; dials != 1 {
+		t.Errorf("expected the client's dialer to be used, got %d dials", dials)
+	}
+
+	if _, err := New(Use(nil)); err != ErrInvalidOptionValue {
+		t.Errorf("expected %v, got %v", ErrInvalidOptionValue, err)
+	}
+}
@@ -0,0 +1,148 @@
+package oauth2
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// DefaultAssertionLifetime is how long the assertions of JWTBearer are valid
+const DefaultAssertionLifetime = 5 * time.Minute
+
+// JWTConfig is the assertion of the JWT bearer grant (RFC 7523)
+type JWTConfig struct {
+	// Issuer (iss) and Subject (sub) of the assertion. Subject defaults to
+	// the Issuer.
+	Issuer  string
+	Subject string
+	// Audience (aud) of the assertion, the TokenURL by default
+	Audience string
+	// Key signing the assertion: *rsa.PrivateKey (RS256),
+	// *ecdsa.PrivateKey on P-256 (ES256) or ed25519.PrivateKey (EdDSA)
+	Key crypto.Signer
+	// KeyID, if set, is sent as the kid header
+	KeyID string
+	// Lifetime of the assertion, DefaultAssertionLifetime by default
+	Lifetime time.Duration
+	// PrivateClaims are added to the assertion
+	PrivateClaims map[string]interface{}
+}
+
+// JWTBearer returns a TokenSource using the JWT bearer assertion grant. The
+// client credentials of cfg are sent too when a ClientID is set.
+func JWTBearer(c httpclient.Client, cfg Config, jwt JWTConfig) (*TokenSource, error) {
+	alg, err := algorithm(jwt.Key)
+	if err != nil {
+		return nil, err
+	}
+	if jwt.Subject == "" {
+		jwt.Subject = jwt.Issuer
+	}
+	if jwt.Audience == "" {
+		jwt.Audience = cfg.TokenURL
+	}
+	if jwt.Lifetime == 0 {
+		jwt.Lifetime = DefaultAssertionLifetime
+	}
+	ts := newTokenSource(c, cfg)
+	ts.params = func(scopes []string) (url.Values, error) {
+		assertion, err := jwt.assertion(alg, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return url.Values{
+			"grant_type": {GrantTypeJWTBearer},
+			"assertion":  {assertion},
+		}, nil
+	}
+	return ts, nil
+}
+
+// algorithm returns the JWS algorithm of key
+func algorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		if k.Curve.Params().Name != "P-256" {
+			return "", fmt.Errorf("oauth2: unsupported curve %s", k.Curve.Params().Name)
+		}
+		return "ES256", nil
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
+	case nil:
+		return "", errors.New("oauth2: missing JWT signing key")
+	}
+	return "", fmt.Errorf("oauth2: unsupported JWT signing key %T", key)
+}
+
+// assertion returns the signed assertion issued at now
+func (j JWTConfig) assertion(alg string, now time.Time) (string, error) {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if j.KeyID != "" {
+		header["kid"] = j.KeyID
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims := map[string]interface{}{}
+	for k, v := range j.PrivateClaims {
+		claims[k] = v
+	}
+	claims["iss"] = j.Issuer
+	claims["sub"] = j.Subject
+	claims["aud"] = j.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(j.Lifetime).Unix()
+	claims["jti"] = hex.EncodeToString(jti)
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	sig, err := sign(j.Key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// sign returns the JWS signature of data (RFC 7518 section 3)
+func sign(key crypto.Signer, data []byte) ([]byte, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, data), nil
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// the fixed size concatenation of r and s, not ASN.1
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	default:
+		digest := sha256.Sum256(data)
+		return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+}
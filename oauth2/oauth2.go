@@ -0,0 +1,371 @@
+// Package oauth2 implements the OAuth 2.0 client_credentials and
+// refresh_token grants (RFC 6749) and the JWT bearer assertion grant
+// (RFC 7523) as httpclient.TokenSources, fetching tokens with an
+// httpclient.Client.
+//
+//	tokens, err := httpclient.New()
+//	ts := oauth2.ClientCredentials(tokens, oauth2.Config{
+//		TokenURL:     "https://auth.example.com/oauth/token",
+//		ClientID:     id,
+//		ClientSecret: secret,
+//		Scopes:       []string{"bookings:read"},
+//	})
+//	client, err := httpclient.New(httpclient.Auth(ts))
+//
+// The Client fetching tokens must not use the TokenSource itself.
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// Grant types of the token requests
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// AuthStyle is how the client credentials are sent to the token endpoint
+type AuthStyle int
+
+const (
+	// AuthStyleInHeader sends them with HTTP Basic authentication
+	AuthStyleInHeader AuthStyle = iota
+	// AuthStyleInParams sends them as client_id and client_secret parameters
+	AuthStyleInParams
+)
+
+// Config of the token endpoint and client
+type Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	AuthStyle    AuthStyle
+	// Scopes requested by default, see TokenSource.Scoped
+	Scopes []string
+	// Audience, if set, is sent as audience parameter, as required by some
+	// providers for the client_credentials grant
+	Audience string
+	// EndpointParams are added to every token request
+	EndpointParams url.Values
+}
+
+// RetrieveError is returned when the token endpoint does not grant a token
+type RetrieveError struct {
+	StatusCode int
+	// ErrorCode, ErrorDescription and ErrorURI are those of the error
+	// response (RFC 6749 section 5.2), if the body is one
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorURI         string `json:"error_uri"`
+	Body             []byte `json:"-"`
+}
+
+func (e *RetrieveError) Error() string {
+	if e.ErrorCode == "" {
+		return fmt.Sprintf("oauth2: token request failed with status %d: %s", e.StatusCode, e.Body)
+	}
+	msg := fmt.Sprintf("oauth2: token request failed with status %d: %s", e.StatusCode, e.ErrorCode)
+	if e.ErrorDescription != "" {
+		msg += ": " + e.ErrorDescription
+	}
+	return msg
+}
+
+// TokenSource fetches tokens from the token endpoint and caches them per set
+// of scopes until httpclient.DefaultTokenExpiryDelta before they expire. It is
+// safe for concurrent use.
+type TokenSource struct {
+	client httpclient.Client
+	config Config
+	// params returns the grant specific parameters of a token request
+	params func(scopes []string) (url.Values, error)
+
+	// rotating serializes the token requests of a grant rotating the refresh
+	// token, which a concurrent request would use after it was replaced
+	rotating bool
+	rotate   sync.Mutex
+
+	mu           sync.Mutex // guards below
+	tokens       map[string]httpclient.Token
+	calls        map[string]*tokenCall // token requests in flight per scopes
+	refreshToken string
+}
+
+// tokenCall is a token request shared by the callers waiting for it
+type tokenCall struct {
+	done  chan struct{}
+	token httpclient.Token
+	err   error
+}
+
+var _ httpclient.TokenInvalidator = (*TokenSource)(nil)
+
+func newTokenSource(c httpclient.Client, cfg Config) *TokenSource {
+	return &TokenSource{
+		client: c,
+		config: cfg,
+		tokens: make(map[string]httpclient.Token),
+		calls:  make(map[string]*tokenCall),
+	}
+}
+
+// ClientCredentials returns a TokenSource using the client_credentials grant
+func ClientCredentials(c httpclient.Client, cfg Config) *TokenSource {
+	ts := newTokenSource(c, cfg)
+	ts.params = func(scopes []string) (url.Values, error) {
+		v := url.Values{"grant_type": {GrantTypeClientCredentials}}
+		if cfg.Audience != "" {
+			v.Set("audience", cfg.Audience)
+		}
+		return v, nil
+	}
+	return ts
+}
+
+// RefreshToken returns a TokenSource using the refresh_token grant with the
+// given refresh token. A new refresh token issued by the endpoint replaces
+// it, see TokenSource.RefreshToken.
+func RefreshToken(c httpclient.Client, cfg Config, refreshToken string) *TokenSource {
+	ts := newTokenSource(c, cfg)
+	ts.refreshToken = refreshToken
+	ts.rotating = true
+	ts.params = func(scopes []string) (url.Values, error) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		return url.Values{
+			"grant_type":    {GrantTypeRefreshToken},
+			"refresh_token": {ts.refreshToken},
+		}, nil
+	}
+	return ts
+}
+
+// Token returns a token for the scopes of the Config
+func (ts *TokenSource) Token(ctx context.Context) (httpclient.Token, error) {
+	return ts.token(ctx, ts.config.Scopes)
+}
+
+// Scoped returns a TokenSource requesting the given scopes instead of those
+// of the Config, sharing the cache of ts
+func (ts *TokenSource) Scoped(scopes ...string) *ScopedTokenSource {
+	return &ScopedTokenSource{source: ts, scopes: scopes}
+}
+
+// ScopedTokenSource is a TokenSource of other scopes, see TokenSource.Scoped
+type ScopedTokenSource struct {
+	source *TokenSource
+	scopes []string
+}
+
+var _ httpclient.TokenInvalidator = (*ScopedTokenSource)(nil)
+
+// Token returns a token for the scopes of s
+func (s *ScopedTokenSource) Token(ctx context.Context) (httpclient.Token, error) {
+	return s.source.token(ctx, s.scopes)
+}
+
+// Invalidate drops token from the cache of the scopes of s, it implements
+// httpclient.TokenInvalidator
+func (s *ScopedTokenSource) Invalidate(token httpclient.Token) {
+	s.source.invalidate(scopeKey(s.scopes), token)
+}
+
+// RefreshToken returns the current refresh token, the last one issued by the
+// endpoint, e.g. to persist it
+func (ts *TokenSource) RefreshToken() string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.refreshToken
+}
+
+// Invalidate drops token from the cache of the scopes of the Config, it
+// implements httpclient.TokenInvalidator
+func (ts *TokenSource) Invalidate(token httpclient.Token) {
+	ts.invalidate(scopeKey(ts.config.Scopes), token)
+}
+
+// invalidate drops the token cached for the given key if it is token
+func (ts *TokenSource) invalidate(key string, token httpclient.Token) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if t, ok := ts.tokens[key]; ok && t.AccessToken == token.AccessToken {
+		delete(ts.tokens, key)
+	}
+}
+
+// cached returns the token cached for the given key if it is still valid. It
+// must be called with ts.mu held.
+func (ts *TokenSource) cached(key string) (httpclient.Token, bool) {
+	t, ok := ts.tokens[key]
+	if !ok || (!t.Expiry.IsZero() && time.Now().After(t.Expiry.Add(-httpclient.DefaultTokenExpiryDelta))) {
+		return httpclient.Token{}, false
+	}
+	return t, true
+}
+
+// token returns the token cached for the given scopes, or fetches one.
+// Concurrent callers of the same scopes share a single token request, which is
+// not cancelled with ctx as other callers may be waiting for it.
+func (ts *TokenSource) token(ctx context.Context, scopes []string) (httpclient.Token, error) {
+	key := scopeKey(scopes)
+	ts.mu.Lock()
+	if t, ok := ts.cached(key); ok {
+		ts.mu.Unlock()
+		return t, nil
+	}
+	call, ok := ts.calls[key]
+	if !ok {
+		call = &tokenCall{done: make(chan struct{})}
+		ts.calls[key] = call
+		go ts.fetch(context.WithoutCancel(ctx), key, scopes, call)
+	}
+	ts.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return httpclient.Token{}, ctx.Err()
+	}
+}
+
+// fetch requests a token for the given scopes and caches it
+func (ts *TokenSource) fetch(ctx context.Context, key string, scopes []string, call *tokenCall) {
+	defer close(call.done)
+	if ts.rotating {
+		ts.rotate.Lock()
+		defer ts.rotate.Unlock()
+	}
+	call.token, call.err = ts.newToken(ctx, scopes)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.calls, key)
+	if call.err == nil {
+		ts.tokens[key] = call.token
+	}
+}
+
+// newToken requests a token for the given scopes from the token endpoint
+func (ts *TokenSource) newToken(ctx context.Context, scopes []string) (httpclient.Token, error) {
+	params, err := ts.params(scopes)
+	if err != nil {
+		return httpclient.Token{}, err
+	}
+	if len(scopes) > 0 {
+		params.Set("scope", strings.Join(scopes, " "))
+	}
+	res, err := ts.request(ctx, params)
+	if err != nil {
+		return httpclient.Token{}, err
+	}
+	t := httpclient.Token{AccessToken: res.AccessToken, TokenType: res.TokenType}
+	if res.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	if res.RefreshToken != "" {
+		ts.mu.Lock()
+		ts.refreshToken = res.RefreshToken
+		ts.mu.Unlock()
+	}
+	return t, nil
+}
+
+// tokenResponse is the successful response of the token endpoint
+type tokenResponse struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    expiresIn `json:"expires_in"`
+	RefreshToken string    `json:"refresh_token"`
+	Scope        string    `json:"scope"`
+}
+
+// expiresIn is a number of seconds, some providers send it as a string
+type expiresIn int64
+
+func (e *expiresIn) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("oauth2: invalid expires_in %s", b)
+	}
+	*e = expiresIn(n)
+	return nil
+}
+
+// request posts a token request with the given parameters
+func (ts *TokenSource) request(ctx context.Context, params url.Values) (*tokenResponse, error) {
+	for k, vs := range ts.config.EndpointParams {
+		params[k] = vs
+	}
+	header := http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Accept":       {"application/json"},
+	}
+	if ts.config.ClientID != "" {
+		if ts.config.AuthStyle == AuthStyleInParams {
+			params.Set("client_id", ts.config.ClientID)
+			if ts.config.ClientSecret != "" {
+				params.Set("client_secret", ts.config.ClientSecret)
+			}
+		} else {
+			// RFC 6749 section 2.3.1
+			creds := url.QueryEscape(ts.config.ClientID) + ":" + url.QueryEscape(ts.config.ClientSecret)
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(creds)))
+		}
+	}
+
+	var tr tokenResponse
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			e := &RetrieveError{StatusCode: res.StatusCode, Body: body}
+			json.Unmarshal(body, e)
+			return e
+		}
+		if err := json.Unmarshal(body, &tr); err != nil {
+			return fmt.Errorf("oauth2: invalid token response: %w", err)
+		}
+		if tr.AccessToken == "" {
+			return errors.New("oauth2: token response without access_token")
+		}
+		return nil
+	}
+	err := ts.client.Post(ctx, rh, ts.config.TokenURL, strings.NewReader(params.Encode()), httpclient.SetHeaders(header))
+	if err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+// scopeKey returns the cache key of a set of scopes
+func scopeKey(scopes []string) string {
+	sorted := append([]string(nil), scopes...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
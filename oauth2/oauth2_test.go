@@ -0,0 +1,340 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// tokenServer is a token endpoint recording the requests it got
+type tokenServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []url.Values
+	issued   int
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	ts := new(tokenServer)
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("invalid token request: %v", err)
+		}
+		form := r.PostForm
+		if id, secret, ok := r.BasicAuth(); ok {
+			form.Set("basic", id+":"+secret)
+		}
+		ts.mu.Lock()
+		ts.requests = append(ts.requests, form)
+		ts.issued++
+		n := ts.issued
+		ts.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if form.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "revoked"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("access-%d", n),
+			"token_type":    "bearer",
+			"expires_in":    "3600",
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tokenServer) last() url.Values {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.requests[len(ts.requests)-1]
+}
+
+func newClient(t *testing.T, opts ...httpclient.Option) httpclient.Client {
+	c, err := httpclient.New(opts...)
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestClientCredentials(t *testing.T) {
+	server := newTokenServer(t)
+	ts := ClientCredentials(newClient(t), Config{
+		TokenURL:     server.URL,
+		ClientID:     "id",
+		ClientSecret: "s3cr:t",
+		Scopes:       []string{"read", "write"},
+		Audience:     "https://api.example.com",
+	})
+	ctx := context.Background()
+
+	token, err := ts.Token(ctx)
+	if err != nil {
+		t.Fatalf("trouble when fetching token: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.Expiry.IsZero() {
+		t.Errorf("unexpected token %+v", token)
+	}
+	req := server.last()
+	for k, expected := range map[string]string{
+		"grant_type": GrantTypeClientCredentials,
+		"scope":      "read write",
+		"audience":   "https://api.example.com",
+		"basic":      "id:s3cr%3At",
+	} {
+		if req.Get(k) != expected {
+			t.Errorf("expected %s=%s, got %s", k, expected, req.Get(k))
+		}
+	}
+
+	// cached per scope set
+	if token, _ := ts.Token(ctx); token.AccessToken != "access-1" {
+		t.Errorf("expected the cached token, got %s", token.AccessToken)
+	}
+	if token, _ := ts.Scoped("write", "read").Token(ctx); token.AccessToken != "access-1" {
+		t.Errorf("expected the cached token for the same scopes, got %s", token.AccessToken)
+	}
+	if token, _ := ts.Scoped("admin").Token(ctx); token.AccessToken != "access-2" || server.last().Get("scope") != "admin" {
+		t.Errorf("expected a new token for other scopes, got %s", token.AccessToken)
+	}
+	ts.Invalidate(httpclient.Token{AccessToken: "access-1"})
+	if token, _ := ts.Token(ctx); token.AccessToken != "access-3" {
+		t.Errorf("expected a new token once invalidated, got %s", token.AccessToken)
+	}
+}
+
+func TestConcurrentFetches(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	var calls int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		n := atomic.AddInt64(&calls, 1)
+		if r.PostForm.Get("scope") == "slow" {
+			close(arrived)
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","expires_in":3600}`, n)
+	}))
+	defer s.Close()
+	ts := ClientCredentials(newClient(t), Config{TokenURL: s.URL, Scopes: []string{"slow"}})
+	ctx := context.Background()
+
+	// callers of the same scopes share a token request
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.Token(ctx); err != nil {
+				t.Errorf("trouble when fetching token: %v", err)
+			}
+		}()
+	}
+
+	// other scopes are not held back by a pending request
+	<-arrived
+	start := time.Now()
+	if _, err := ts.Scoped("fast").Token(ctx); err != nil {
+		t.Fatalf("trouble when fetching token: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected the token of other scopes not to wait, took %s", d)
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt64(&calls); n != 2 {
+		t.Errorf("expected 2 token requests, got %d", n)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	server := newTokenServer(t)
+	ts := RefreshToken(newClient(t), Config{TokenURL: server.URL, ClientID: "id", AuthStyle: AuthStyleInParams}, "refresh-0")
+
+	if _, err := ts.Token(context.Background()); err != nil {
+		t.Fatalf("trouble when fetching token: %v", err)
+	}
+	req := server.last()
+	if req.Get("grant_type") != GrantTypeRefreshToken || req.Get("refresh_token") != "refresh-0" || req.Get("client_id") != "id" {
+		t.Errorf("unexpected token request %v", req)
+	}
+	if ts.RefreshToken() != "refresh-1" {
+		t.Errorf("expected the refresh token to be rotated, got %s", ts.RefreshToken())
+	}
+
+	revoked := RefreshToken(newClient(t), Config{TokenURL: server.URL}, "revoked")
+	_, err := revoked.Token(context.Background())
+	var re *RetrieveError
+	if !errors.As(err, &re) || re.StatusCode != http.StatusBadRequest || re.ErrorCode != "invalid_grant" {
+		t.Errorf("expected an invalid_grant RetrieveError, got %v", err)
+	}
+}
+
+func TestJWTBearer(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	verify := map[string]func(data, sig []byte) bool{
+		"EdDSA": func(data, sig []byte) bool {
+			return ed25519.Verify(edKey.Public().(ed25519.PublicKey), data, sig)
+		},
+		"ES256": func(data, sig []byte) bool {
+			digest := sha256.Sum256(data)
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			return len(sig) == 64 && ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s)
+		},
+		"RS256": func(data, sig []byte) bool {
+			digest := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig) == nil
+		},
+	}
+	server := newTokenServer(t)
+	for alg, key := range map[string]crypto.Signer{"EdDSA": edKey, "ES256": ecKey, "RS256": rsaKey} {
+		t.Run(alg, func(t *testing.T) {
+			ts, err := JWTBearer(newClient(t), Config{TokenURL: server.URL, Scopes: []string{"read"}}, JWTConfig{
+				Issuer:        "service@example.com",
+				Key:           key,
+				KeyID:         "k1",
+				PrivateClaims: map[string]interface{}{"tenant": "gadventures"},
+			})
+			if err != nil {
+				t.Fatalf("trouble when creating the token source: %v", err)
+			}
+			if _, err := ts.Token(context.Background()); err != nil {
+				t.Fatalf("trouble when fetching token: %v", err)
+			}
+			req := server.last()
+			if req.Get("grant_type") != GrantTypeJWTBearer || req.Get("scope") != "read" {
+				t.Errorf("unexpected token request %v", req)
+			}
+
+			parts := strings.Split(req.Get("assertion"), ".")
+			if len(parts) != 3 {
+				t.Fatalf("invalid assertion %s", req.Get("assertion"))
+			}
+			var header, claims map[string]interface{}
+			decode := func(s string, v interface{}) {
+				b, err := base64.RawURLEncoding.DecodeString(s)
+				if err == nil {
+					err = json.Unmarshal(b, v)
+				}
+				if err != nil {
+					t.Fatalf("invalid assertion part %s: %v", s, err)
+				}
+			}
+			decode(parts[0], &header)
+			decode(parts[1], &claims)
+			if header["alg"] != alg || header["kid"] != "k1" {
+				t.Errorf("unexpected header %v", header)
+			}
+			if claims["iss"] != "service@example.com" || claims["sub"] != "service@example.com" ||
+				claims["aud"] != server.URL || claims["tenant"] != "gadventures" ||
+				claims["exp"].(float64)-claims["iat"].(float64) != DefaultAssertionLifetime.Seconds() {
+				t.Errorf("unexpected claims %v", claims)
+			}
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			if !verify[alg]([]byte(parts[0]+"."+parts[1]), sig) {
+				t.Errorf("invalid %s signature", alg)
+			}
+		})
+	}
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	for _, key := range []crypto.Signer{nil, p384} {
+		if _, err := JWTBearer(newClient(t), Config{}, JWTConfig{Key: key}); err == nil {
+			t.Errorf("expected an error for key %T", key)
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	server := newTokenServer(t)
+	var mu sync.Mutex
+	revoked := map[string]bool{"Bearer access-1": true}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if revoked[r.Header.Get("Authorization")] {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	ts := ClientCredentials(newClient(t), Config{TokenURL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	c := newClient(t, httpclient.Auth(ts))
+	var status int
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		status = res.StatusCode
+		return nil
+	}
+	if err := c.Get(context.Background(), rh, api.URL); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	// the revoked token was dropped from the cache of the source too
+	if status != http.StatusOK {
+		t.Errorf("expected the request to be retried with a new token, got %d", status)
+	}
+}
+
+func TestAuthScoped(t *testing.T) {
+	server := newTokenServer(t)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer access-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	ts := ClientCredentials(newClient(t), Config{TokenURL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	c := newClient(t, httpclient.Auth(ts.Scoped("admin")))
+	var status int
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		status = res.StatusCode
+		return nil
+	}
+	if err := c.Get(context.Background(), rh, api.URL); err != nil {
+		t.Fatalf("trouble when making GET request: %v", err)
+	}
+	if status != http.StatusOK || server.last().Get("scope") != "admin" {
+		t.Errorf("expected the request to be retried with a new admin token, got %d", status)
+	}
+	if token, _ := ts.Scoped("admin").Token(context.Background()); token.AccessToken != "access-2" {
+		t.Errorf("expected the rejected token to be dropped from the cache, got %s", token.AccessToken)
+	}
+}
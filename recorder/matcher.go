@@ -0,0 +1,47 @@
+package recorder
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Matcher reports whether the recorded request matches the outgoing one. A
+// recorded interaction is replayed when all the Recorder's matchers agree.
+type Matcher func(req, recorded Request) bool
+
+// DefaultMatchers compare the method and URL of requests
+var DefaultMatchers = []Matcher{MatchMethod, MatchURL}
+
+// MatchMethod compares the methods of requests
+func MatchMethod(req, recorded Request) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL compares the URLs of requests, including the query string
+func MatchURL(req, recorded Request) bool {
+	return req.URL == recorded.URL
+}
+
+// MatchBody compares the bodies of requests byte for byte
+func MatchBody(req, recorded Request) bool {
+	return bytes.Equal(req.Body, recorded.Body)
+}
+
+// MatchHeaders returns a Matcher comparing the values of the given headers
+func MatchHeaders(names ...string) Matcher {
+	return func(req, recorded Request) bool {
+		for _, name := range names {
+			name = http.CanonicalHeaderKey(name)
+			a, b := req.Header[name], recorded.Header[name]
+			if len(a) != len(b) {
+				return false
+			}
+			for i := range a {
+				if a[i] != b[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
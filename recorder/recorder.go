@@ -0,0 +1,365 @@
+// Package recorder records the HTTP interactions of tests to cassette files
+// and replays them deterministically, so that tests run offline and do not
+// depend on the availability of third party services.
+//
+//	rec, err := recorder.New("testdata/users.jsonl", recorder.WithMode(recorder.ModeRecordMissing))
+//	client, err := httpclient.New(httpclient.Use(rec.Middleware()))
+//
+// A cassette is a JSONL file with one Interaction per line.
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// Mode is the way a Recorder handles requests
+type Mode int
+
+const (
+	// ModeReplay answers requests from the cassette, requests without a
+	// recorded interaction fail with ErrNoInteraction
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the network and records them to a new
+	// cassette, replacing the previous one
+	ModeRecord
+	// ModeRecordMissing replays the recorded interactions and records the
+	// requests that are not in the cassette yet
+	ModeRecordMissing
+	// ModePassthrough sends requests to the network without recording them
+	ModePassthrough
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModeRecordMissing:
+		return "record-missing"
+	case ModePassthrough:
+		return "passthrough"
+	}
+	return "Mode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ErrNoInteraction is returned in replay mode for requests that match none of
+// the recorded interactions that were not replayed yet
+var ErrNoInteraction = errors.New("recorder: no recorded interaction matches the request")
+
+// Option is the functional options type of New
+type Option func(*Recorder) error
+
+// WithMode changes the mode of the Recorder (ModeReplay by default)
+func WithMode(m Mode) Option {
+	return func(r *Recorder) error {
+		if m < ModeReplay || m > ModePassthrough {
+			return fmt.Errorf("recorder: invalid mode %v", m)
+		}
+		r.mode = m
+		return nil
+	}
+}
+
+// WithMatchers replaces DefaultMatchers, e.g. to tell apart requests to the
+// same URL by their body or headers
+func WithMatchers(matchers ...Matcher) Option {
+	return func(r *Recorder) error {
+		r.matchers = matchers
+		return nil
+	}
+}
+
+// WithTransport changes the RoundTripper used to send requests to the network
+// (http.DefaultTransport by default)
+func WithTransport(rt http.RoundTripper) Option {
+	return func(r *Recorder) error {
+		if rt == nil {
+			return errors.New("recorder: nil transport")
+		}
+		r.transport = rt
+		return nil
+	}
+}
+
+// DropHeaders keeps the given request and response headers, e.g. X-API-Key,
+// out of the cassette, in addition to httpclient.DefaultRedactedHeaders
+func DropHeaders(names ...string) Option {
+	return func(r *Recorder) error {
+		for _, name := range names {
+			r.dropHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+		return nil
+	}
+}
+
+// KeepHeaders records the given headers of httpclient.DefaultRedactedHeaders,
+// e.g. Cookie, which are kept out of the cassette by default. Beware of
+// committing secrets with the cassette.
+func KeepHeaders(names ...string) Option {
+	return func(r *Recorder) error {
+		for _, name := range names {
+			delete(r.dropHeaders, http.CanonicalHeaderKey(name))
+		}
+		return nil
+	}
+}
+
+// RedactQueryParams replaces the values of the given query parameters, e.g.
+// api_key, with httpclient.RedactedValue in the cassette. Requests are matched
+// on their redacted URL.
+func RedactQueryParams(names ...string) Option {
+	return func(r *Recorder) error {
+		for _, name := range names {
+			r.redactQuery[name] = true
+		}
+		return nil
+	}
+}
+
+// Recorder is an http.RoundTripper recording interactions to a cassette and
+// replaying them. Each recorded interaction is replayed once, in the order
+// they were recorded, so a sequence of identical requests gets the sequence
+// of recorded responses.
+type Recorder struct {
+	path        string
+	mode        Mode
+	matchers    []Matcher
+	transport   http.RoundTripper
+	dropHeaders map[string]bool
+	redactQuery map[string]bool
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// New returns a Recorder using the cassette at path. In ModeRecord the
+// cassette is truncated, in the other modes its interactions are loaded.
+func New(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:        path,
+		matchers:    DefaultMatchers,
+		transport:   http.DefaultTransport,
+		dropHeaders: make(map[string]bool),
+		redactQuery: make(map[string]bool),
+	}
+	for _, name := range httpclient.DefaultRedactedHeaders {
+		r.dropHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	switch r.mode {
+	case ModeRecord:
+		var f *os.File
+		if f, err = os.Create(path); err == nil {
+			err = f.Close()
+		}
+	case ModeReplay, ModeRecordMissing:
+		r.interactions, err = load(path)
+		r.replayed = make([]bool, len(r.interactions))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Mode returns the mode of the Recorder
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.roundTrip(req, r.transport)
+}
+
+// Middleware returns the Recorder as an httpclient.Middleware, see
+// httpclient.Use. The requests that are not replayed are sent with the
+// client's Transport instead of the one given with WithTransport.
+func (r *Recorder) Middleware() httpclient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return r.roundTrip(req, next)
+		})
+	}
+}
+
+func (r *Recorder) roundTrip(req *http.Request, transport http.RoundTripper) (*http.Response, error) {
+	if r.mode == ModePassthrough {
+		return transport.RoundTrip(req)
+	}
+	recorded, req, err := r.request(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode != ModeRecord {
+		if i, ok := r.match(recorded); ok {
+			return i.Response.response(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recorded.Method, recorded.URL)
+		}
+	}
+	return r.record(req, recorded, transport)
+}
+
+// match returns the first interaction matching req that was not replayed yet
+func (r *Recorder) match(req Request) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+next:
+	for i, interaction := range r.interactions {
+		if r.replayed[i] {
+			continue
+		}
+		for _, m := range r.matchers {
+			if !m(req, interaction.Request) {
+				continue next
+			}
+		}
+		r.replayed[i] = true
+		return interaction, true
+	}
+	return Interaction{}, false
+}
+
+// record sends req to the network and appends the interaction to the cassette
+func (r *Recorder) record(req *http.Request, recorded Request, transport http.RoundTripper) (*http.Response, error) {
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	interaction := Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Proto:      res.Proto,
+			Header:     r.header(res.Header),
+			Body:       body,
+		},
+		RecordedAt: time.Now().UTC(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := appendTo(r.path, interaction); err != nil {
+		return nil, err
+	}
+	r.interactions = append(r.interactions, interaction)
+	r.replayed = append(r.replayed, true)
+	return res, nil
+}
+
+// request returns the recorded form of req, reading its body, along with a
+// clone of req with a body that can be sent again
+func (r *Recorder) request(req *http.Request) (Request, *http.Request, error) {
+	recorded := Request{
+		Method: req.Method,
+		URL:    r.url(req.URL),
+		Header: r.header(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return Request{}, nil, err
+		}
+		// the request must not be modified by a RoundTripper
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		recorded.Body = body
+	}
+	return recorded, req, nil
+}
+
+// url returns u without its user info and with the redacted query parameters
+// replaced
+func (r *Recorder) url(u *url.URL) string {
+	recorded := *u
+	recorded.User = nil
+	if len(r.redactQuery) > 0 && u.RawQuery != "" {
+		query := u.Query()
+		changed := false
+		for k, vs := range query {
+			if r.redactQuery[k] {
+				for i := range vs {
+					vs[i] = httpclient.RedactedValue
+				}
+				changed = true
+			}
+		}
+		if changed {
+			recorded.RawQuery = query.Encode()
+		}
+	}
+	return recorded.String()
+}
+
+// header returns a copy of h without the dropped headers
+func (r *Recorder) header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if !r.dropHeaders[http.CanonicalHeaderKey(k)] {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// response returns the replayed response to req
+func (res Response) response(req *http.Request) *http.Response {
+	proto := res.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, _ := http.ParseHTTPVersion(proto)
+	status := res.Status
+	if status == "" {
+		status = strconv.Itoa(res.StatusCode) + " " + http.StatusText(res.StatusCode)
+	}
+	header := res.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    res.StatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}
+}
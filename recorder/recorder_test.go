@@ -0,0 +1,228 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gadventures/httpclient"
+)
+
+func TestRecorder(t *testing.T) {
+	var hits int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0x00, 0xfe})
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Method+" "+r.URL.Path+" "+string(b)+" "+r.Header.Get("X-Tenant"))
+	}))
+	defer s.Close()
+
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+	do := func(rec *Recorder, method, path, body, tenant string) (string, error) {
+		c, err := httpclient.New(httpclient.Use(rec.Middleware()))
+		if err != nil {
+			t.Fatalf("trouble when creating the client: %v", err)
+		}
+		defer c.Close()
+		var received string
+		rh := func(ctx context.Context, res *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(res.Body)
+			received = string(b)
+			return err
+		}
+		var r io.Reader
+		if body != "" {
+			r = strings.NewReader(body)
+		}
+		err = c.Do(context.Background(), rh, method, s.URL+path, r,
+			httpclient.SetHeaders(http.Header{"X-Tenant": {tenant}, "Authorization": {"secret"}}))
+		return received, err
+	}
+
+	// record
+	rec, err := New(cassette, WithMode(ModeRecord))
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	for _, tenant := range []string{"a", "b"} {
+		if _, err := do(rec, http.MethodPost, "/users", `{"name":"bob"}`, tenant); err != nil {
+			t.Fatalf("trouble when recording: %v", err)
+		}
+	}
+	if _, err := do(rec, http.MethodGet, "/binary", "", "a"); err != nil {
+		t.Fatalf("trouble when recording: %v", err)
+	}
+	b, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 3 {
+		t.Errorf("expected 3 recorded interactions, got %d", lines)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("expected the Authorization header to be dropped, got %s", b)
+	}
+	if !strings.Contains(string(b), `"base64":"/wD+"`) {
+		t.Errorf("expected the binary body to be base64 encoded, got %s", b)
+	}
+
+	// replay
+	recorded := hits
+	tests := []struct {
+		name     string
+		matchers []Matcher
+		method   string
+		path     string
+		body     string
+		tenant   string
+		expected string
+		err      error
+	}{
+		{"default matchers", nil, http.MethodPost, "/users", `{"name":"alice"}`, "b", `POST /users {"name":"bob"} a`, nil},
+		{"match body", []Matcher{MatchMethod, MatchURL, MatchBody}, http.MethodPost, "/users", `{"name":"alice"}`, "a", "", ErrNoInteraction},
+		{"match headers", []Matcher{MatchMethod, MatchURL, MatchHeaders("X-Tenant")}, http.MethodPost, "/users", `{"name":"bob"}`, "b", `POST /users {"name":"bob"} b`, nil},
+		{"match method", nil, http.MethodPut, "/users", `{"name":"bob"}`, "a", "", ErrNoInteraction},
+		{"binary", nil, http.MethodGet, "/binary", "", "a", "\xff\x00\xfe", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []Option
+			if tc.matchers != nil {
+				opts = append(opts, WithMatchers(tc.matchers...))
+			}
+			rec, err := New(cassette, opts...)
+			if err != nil {
+				t.Fatalf("trouble when creating the recorder: %v", err)
+			}
+			received, err := do(rec, tc.method, tc.path, tc.body, tc.tenant)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if received != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, received)
+			}
+		})
+	}
+	if hits != recorded {
+		t.Errorf("expected replays not to hit the server, got %d requests", hits-recorded)
+	}
+
+	// interactions are replayed once, in order
+	rec, err = New(cassette)
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	for _, expected := range []string{"a", "b"} {
+		received, err := do(rec, http.MethodPost, "/users", `{"name":"bob"}`, "")
+		if err != nil || !strings.HasSuffix(received, " "+expected) {
+			t.Errorf("expected the response for tenant %s, got %q %v", expected, received, err)
+		}
+	}
+	if _, err := do(rec, http.MethodPost, "/users", `{"name":"bob"}`, ""); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("expected %v once the interactions were replayed, got %v", ErrNoInteraction, err)
+	}
+
+	// record missing
+	rec, err = New(cassette, WithMode(ModeRecordMissing))
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := do(rec, http.MethodGet, "/binary", "", "a"); err != nil {
+			t.Fatalf("trouble when recording missing: %v", err)
+		}
+	}
+	if hits != recorded+2 {
+		t.Errorf("expected 2 missing requests to be recorded, got %d", hits-recorded)
+	}
+	rec, err = New(cassette)
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	if n := len(rec.interactions); n != 5 {
+		t.Errorf("expected 5 interactions in the cassette, got %d", n)
+	}
+
+	// sensitive headers recorded on demand
+	kept := filepath.Join(t.TempDir(), "kept.jsonl")
+	rec, err = New(kept, WithMode(ModeRecord), KeepHeaders("Authorization"), DropHeaders("X-Tenant"))
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	if _, err := do(rec, http.MethodGet, "/binary", "", "tenant-a"); err != nil {
+		t.Fatalf("trouble when recording: %v", err)
+	}
+	if b, _ := os.ReadFile(kept); !strings.Contains(string(b), "secret") || strings.Contains(string(b), "tenant-a") {
+		t.Errorf("expected the Authorization header to be kept and X-Tenant dropped, got %s", b)
+	}
+
+	// user info and redacted query parameters are kept out of the cassette,
+	// and the request is left as it was given
+	redacted := filepath.Join(t.TempDir(), "redacted.jsonl")
+	send := func(rec *Recorder, key string) error {
+		u, _ := url.Parse(s.URL + "/users?api_key=" + key + "&page=2")
+		u.User = url.UserPassword("alice", "hunter2")
+		req, _ := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(`{"name":"bob"}`))
+		body := req.Body
+		res, err := rec.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if req.Body != body {
+			t.Errorf("expected the body of the request not to be replaced")
+		}
+		return nil
+	}
+	rec, err = New(redacted, WithMode(ModeRecord), RedactQueryParams("api_key"))
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	if err := send(rec, "k3y"); err != nil {
+		t.Fatalf("trouble when recording: %v", err)
+	}
+	b, _ = os.ReadFile(redacted)
+	for _, secret := range []string{"alice", "hunter2", "k3y"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("expected %s to be kept out of the cassette, got %s", secret, b)
+		}
+	}
+	rec, err = New(redacted, RedactQueryParams("api_key"))
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	if err := send(rec, "other"); err != nil {
+		t.Errorf("expected the request to match the redacted URL, got %v", err)
+	}
+
+	// passthrough
+	rec, err = New(filepath.Join(t.TempDir(), "none.jsonl"), WithMode(ModePassthrough))
+	if err != nil {
+		t.Fatalf("trouble when creating the recorder: %v", err)
+	}
+	before := hits
+	if _, err := do(rec, http.MethodGet, "/binary", "", "a"); err != nil {
+		t.Fatalf("trouble in passthrough: %v", err)
+	}
+	if hits != before+1 {
+		t.Errorf("expected passthrough to hit the server")
+	}
+
+	if _, err := New(cassette, WithMode(Mode(42))); err == nil {
+		t.Errorf("expected an invalid mode to fail")
+	}
+}
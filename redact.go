@@ -0,0 +1,135 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// RedactedValue replaces redacted header values, query parameters and JSON
+// fields in everything the client logs, dumps or adds to spans
+const RedactedValue = "REDACTED"
+
+// DefaultRedactedHeaders are always redacted, unless UnredactHeaders is given
+// some of them. RedactHeaders adds other header names.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Amz-Security-Token",
+}
+
+// redactor implements the redaction policy configured with the RedactHeaders,
+// UnredactHeaders, RedactQueryParams and RedactJSONFields options
+type redactor struct {
+	headers    map[string]bool // canonical header names
+	query      map[string]bool
+	jsonFields [][]string
+}
+
+func newRedactor() *redactor {
+	r := &redactor{
+		headers: make(map[string]bool),
+		query:   make(map[string]bool),
+	}
+	r.addHeaders(DefaultRedactedHeaders...)
+	return r
+}
+
+func (r *redactor) addHeaders(names ...string) {
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// Header returns a copy of h with the values of redacted headers replaced
+func (r *redactor) Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			redacted := make([]string, len(vs))
+			for i := range redacted {
+				redacted[i] = RedactedValue
+			}
+			out[k] = redacted
+			continue
+		}
+		out[k] = vs
+	}
+	return out
+}
+
+// URL returns u as a string with the password and redacted query parameters
+// replaced
+func (r *redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+	if _, ok := u.User.Password(); ok {
+		redacted.User = url.UserPassword(u.User.Username(), RedactedValue)
+	}
+	if len(r.query) > 0 && u.RawQuery != "" {
+		query := u.Query()
+		changed := false
+		for k, vs := range query {
+			if r.query[k] {
+				for i := range vs {
+					vs[i] = RedactedValue
+				}
+				changed = true
+			}
+		}
+		if changed {
+			redacted.RawQuery = query.Encode()
+		}
+	}
+	return redacted.String()
+}
+
+// JSON returns body with the redacted fields replaced. Bodies that are not
+// valid JSON are returned as they are.
+func (r *redactor) JSON(body []byte) []byte {
+	if len(r.jsonFields) == 0 {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	for _, path := range r.jsonFields {
+		v = redactJSONPath(v, path)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactJSONPath replaces the values at path in v. Arrays are traversed
+// transparently and "*" matches any object key.
+func redactJSONPath(v interface{}, path []string) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		for i := range val {
+			val[i] = redactJSONPath(val[i], path)
+		}
+	case map[string]interface{}:
+		for k := range val {
+			if path[0] != "*" && path[0] != k {
+				continue
+			}
+			if len(path) == 1 {
+				val[k] = RedactedValue
+			} else {
+				val[k] = redactJSONPath(val[k], path[1:])
+			}
+		}
+	}
+	return v
+}
@@ -1,20 +1,67 @@
 package httpclient
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 )
 
+// credentialHeaders are not sent again on redirects to another host, as with
+// net/http
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
 func defaultRedirectPolicy(req *http.Request, via []*http.Request) error {
 	if len(via) > 10 {
 		return fmt.Errorf("too many redirects")
 	}
 
-	// copy headers to current redirect
+	// copy headers to current redirect, but the credentials on another host
 	if len(via) > 0 {
 		for k, v := range via[0].Header {
 			req.Header[k] = v
 		}
+		if req.URL.Host != via[0].URL.Host {
+			for _, k := range credentialHeaders {
+				req.Header.Del(k)
+			}
+		}
 	}
 	return nil
 }
+
+// checkRedirect logs the redirect, applies the configured redirect policy,
+// drops the credentials of the client on another host and calls the
+// OnRedirect hooks
+func (c *client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.logger.Enabled(req.Context(), slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String(LogKeyMethod, req.Method),
+			slog.String(LogKeyURL, c.redact.URL(req.URL)),
+			slog.Int(LogKeyRedirects, len(via)),
+		}
+		if req.Response != nil {
+			attrs = append(attrs, slog.Int(LogKeyStatus, req.Response.StatusCode))
+		}
+		c.logger.LogAttrs(req.Context(), slog.LevelDebug, LogRedirect, attrs...)
+	}
+	if c.redirectFunc != nil {
+		if err := c.redirectFunc(req, via); err != nil {
+			return err
+		}
+	} else if len(via) >= 10 {
+		// the policy of net/http
+		return errors.New("stopped after 10 redirects")
+	}
+	if len(via) > 0 && req.URL.Host != via[0].URL.Host {
+		for _, ch := range c.credentials {
+			if ch.header != "" {
+				req.Header.Del(ch.header)
+			}
+		}
+		for _, k := range credentialHeaders {
+			req.Header.Del(k)
+		}
+	}
+	return c.hooks.redirect(req, via)
+}
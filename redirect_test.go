@@ -1,7 +1,9 @@
 package httpclient
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -11,6 +13,7 @@ func TestRedirect(t *testing.T) {
 	vreq.Header.Add("moo", "baah")
 	via := []*http.Request{vreq}
 
+	vreq.Header.Add("Authorization", "Bearer t0ken")
 	req, _ := http.NewRequest("GET", "https://www.gadventures.com", nil)
 	err := defaultRedirectPolicy(req, via)
 	if err != nil {
@@ -19,6 +22,16 @@ func TestRedirect(t *testing.T) {
 	if req.Header.Get("foo") != "bar" || req.Header.Get("moo") != "baah" {
 		t.Error("expected headers missing from redirect")
 	}
+	if req.Header.Get("Authorization") != "Bearer t0ken" {
+		t.Error("expected the credentials on the same host")
+	}
+	cross, _ := http.NewRequest("GET", "https://example.com", nil)
+	if err := defaultRedirectPolicy(cross, via); err != nil {
+		t.Errorf("redirect failed: %v", err)
+	}
+	if cross.Header.Get("foo") != "bar" || cross.Header.Get("Authorization") != "" {
+		t.Errorf("expected the headers but the credentials on another host, got %v", cross.Header)
+	}
 	for i := 0; i < 10; i++ {
 		via = append(via, vreq)
 	}
@@ -31,3 +44,63 @@ func TestRedirect(t *testing.T) {
 		}
 	}
 }
+
+func TestRedirectCredentials(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Authorization", r.Header.Get("Authorization"))
+		w.Header().Set("X-Received-Key", r.Header.Get("X-Api-Key"))
+		w.Header().Set("X-Received-Foo", r.Header.Get("Foo"))
+	}
+	other := httptest.NewServer(http.HandlerFunc(echo))
+	defer other.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", echo)
+	mux.HandleFunc("/same", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/cross", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/echo", http.StatusFound)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	key := CredentialProviderFunc(func(ctx context.Context) (Credential, error) {
+		return Credential{Token: "key"}, nil
+	})
+	for _, policy := range []Option{
+		RedirectPolicy(defaultRedirectPolicy),
+		RedirectPolicy(func(req *http.Request, via []*http.Request) error {
+			for k, v := range via[0].Header {
+				req.Header[k] = v
+			}
+			return nil
+		}),
+	} {
+		c, err := New(BasicAuth("Aladdin", "open sesame"), APIKey("X-API-Key", key), policy)
+		if err != nil {
+			t.Fatalf("trouble when creating the client: %v", err)
+		}
+		defer c.Close()
+		get := func(path string) http.Header {
+			var header http.Header
+			rh := func(ctx context.Context, res *http.Response, err error) error {
+				if err != nil {
+					return err
+				}
+				header = res.Header
+				return nil
+			}
+			if err := c.Get(context.Background(), rh, s.URL+path, SetHeaders(http.Header{"Foo": {"bar"}})); err != nil {
+				t.Fatalf("trouble when making GET request: %v", err)
+			}
+			return header
+		}
+
+		if h := get("/same"); h.Get("X-Authorization") == "" || h.Get("X-Received-Key") != "key" || h.Get("X-Received-Foo") != "bar" {
+			t.Errorf("expected the credentials on the same host, got %v", h)
+		}
+		if h := get("/cross"); h.Get("X-Authorization") != "" || h.Get("X-Received-Key") != "" || h.Get("X-Received-Foo") != "bar" {
+			t.Errorf("expected the headers but the credentials on another host, got %v", h)
+		}
+	}
+}
@@ -31,6 +31,15 @@ func DelHeaders(headers http.Header) RequestOption {
 	}
 }
 
+// SetBasicAuth sets the Authorization header of the request to the HTTP Basic
+// credentials of the given user, replacing those of the BasicAuth Option
+func SetBasicAuth(username, password string) RequestOption {
+	return func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	}
+}
+
 // SetHeaders allows for certain headers to be replaced when making a request
 func SetHeaders(headers http.Header) RequestOption {
 	return func(req *http.Request) error {
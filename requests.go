@@ -3,7 +3,11 @@ package httpclient
 import (
 	"context"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
 )
 
 // Do executes specified HTTP method with provided body (if not nil) and
@@ -20,29 +24,170 @@ func (c *client) Do(
 	if err != nil {
 		return err
 	}
-	req = req.WithContext(ctx)
+	tracker := new(connTracker)
+	defer tracker.release()
+	timer := newTimer()
+	reqCtx := httptrace.WithClientTrace(ctx, c.clientTrace(req, tracker))
+	reqCtx = httptrace.WithClientTrace(reqCtx, timer.clientTrace())
+	reqCtx = context.WithValue(reqCtx, timingsKey{}, timer)
+	reqCtx = context.WithValue(reqCtx, hookStateKey{}, &hookState{attempt: 1, timer: timer, hooks: &c.hooks})
+	// canceled to stop the drain of the response body, see closeBody
+	reqCtx, cancel := context.WithCancel(reqCtx)
+	defer cancel()
+	req = req.WithContext(reqCtx)
 	// copy headers from client
 	for k, v := range c.headers {
 		for _, dv := range v {
 			req.Header.Add(k, dv)
 		}
 	}
+	if err := c.setCredentials(req); err != nil {
+		return err
+	}
 	// apply any request options that may have been passed
 	for _, opt := range opts {
 		if err := opt(req); err != nil {
 			return err
 		}
 	}
+	// an Authorization header set for the request wins over the Auth option
+	var token Token
+	authorize := c.auth != nil && req.Header.Get("Authorization") == ""
+	if authorize {
+		if token, err = c.authorize(req); err != nil {
+			return err
+		}
+	}
+	if err := c.hooks.request(req); err != nil {
+		return err
+	}
 	// make the request and return the response
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		c.logger.LogAttrs(ctx, slog.LevelDebug, LogRequestStart,
+			slog.String(LogKeyMethod, req.Method),
+			slog.String(LogKeyURL, c.redact.URL(req.URL)))
+	}
 	res, err := c.client.Do(req)
+	if authorize && err == nil && res.StatusCode == http.StatusUnauthorized {
+		req, res, err = c.retryAuth(req, res, token)
+	}
+	c.logRequestEnd(ctx, req, res, err, timer.timings())
+	c.hooks.response(req, res, err)
+	if c.logCurl && (err != nil || res.StatusCode >= http.StatusBadRequest) {
+		c.logCurlCommand(ctx, req)
+	}
+	defer timer.report(req)
 	if res != nil {
 		if res.Body != nil {
-			defer res.Body.Close() // idempotent
+			res.Body = timer.body(res.Body)
+			defer c.closeBody(res.Body, cancel)
 		}
 	}
 	return onReponse(ctx, res, err)
 }
 
+// clientTrace returns the httptrace hooks used to observe the connection
+// handling of a single request
+func (c *client) clientTrace(req *http.Request, tracker *connTracker) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			var connID int64
+			if cw, ok := unwrapConn(info.Conn); ok {
+				connID = cw.id
+				tracker.track(cw)
+			}
+			if !c.logger.Enabled(req.Context(), slog.LevelDebug) {
+				return
+			}
+			c.logger.LogAttrs(req.Context(), slog.LevelDebug, LogConnAcquired,
+				slog.Int64(LogKeyConnID, connID),
+				slog.String(LogKeyAddr, info.Conn.RemoteAddr().String()),
+				slog.String(LogKeyMethod, req.Method),
+				slog.String(LogKeyURL, c.redact.URL(req.URL)),
+				slog.Bool(LogKeyReused, info.Reused),
+				slog.Duration(LogKeyIdleTime, info.IdleTime))
+		},
+	}
+}
+
+// logRequestEnd logs the outcome of a request once the response headers were
+// received. Failed requests and server errors are logged as warnings.
+func (c *client) logRequestEnd(ctx context.Context, req *http.Request, res *http.Response, err error, t Timings) {
+	level := slog.LevelInfo
+	if err != nil || res != nil && res.StatusCode >= http.StatusInternalServerError {
+		level = slog.LevelWarn
+	}
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String(LogKeyMethod, req.Method),
+		slog.String(LogKeyURL, c.redact.URL(req.URL)),
+		slog.Duration(LogKeyDuration, t.Total),
+		slog.Int64(LogKeyConnID, t.ConnID),
+		slog.Bool(LogKeyReused, t.Reused),
+	}
+	if res != nil {
+		attrs = append(attrs, slog.Int(LogKeyStatus, res.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String(LogKeyError, err.Error()))
+	}
+	c.logger.LogAttrs(ctx, level, LogRequestEnd, attrs...)
+}
+
+// logCurlCommand logs the redacted curl command reproducing a failed request
+func (c *client) logCurlCommand(ctx context.Context, req *http.Request) {
+	cmd, err := toCurl(req, c.redact)
+	if err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelWarn, LogCurl, slog.String(LogKeyError, err.Error()))
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelWarn, LogCurl, slog.String(LogKeyCurl, cmd))
+}
+
+// connTracker marks the connection serving a request as active until the
+// request is done. Redirects hand the request a new connection, at which
+// point the previous one is released.
+type connTracker struct {
+	mu   sync.Mutex
+	conn *connWrapper
+}
+
+func (t *connTracker) track(cw *connWrapper) {
+	cw.acquire()
+	t.mu.Lock()
+	prev := t.conn
+	t.conn = cw
+	t.mu.Unlock()
+	if prev != nil {
+		prev.release()
+	}
+}
+
+func (t *connTracker) release() {
+	t.mu.Lock()
+	prev := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+	if prev != nil {
+		prev.release()
+	}
+}
+
+// closeBody discards up to maxDrainBytes of whatever the ResponseHandler left
+// unread and closes the body. Only bodies read to EOF let the Transport return
+// HTTP/1.1 connections to the idle pool. A body still being produced after
+// maxDrainDuration is given up on by canceling the request.
+func (c *client) closeBody(body io.ReadCloser, cancel context.CancelFunc) {
+	if c.maxDrainBytes > 0 {
+		t := time.AfterFunc(maxDrainDuration, cancel)
+		io.CopyN(io.Discard, body, c.maxDrainBytes)
+		t.Stop()
+	}
+	body.Close() // idempotent
+}
+
 // Get executes a get request and calls the response handler with the result
 func (c *client) Get(
 	ctx context.Context,
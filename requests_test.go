@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -216,3 +219,80 @@ func TestPost(t *testing.T) {
 		t.Errorf("trouble when making POST request: %v", err)
 	}
 }
+
+func TestDrainBody(t *testing.T) {
+	body := strings.Repeat("x", 1<<20)
+	tests := []struct {
+		name     string
+		drain    int64
+		expConns int64
+	}{
+		{"drained bodies reuse the conn", 2 << 20, 1},
+		{"draining disabled", 0, 3},
+		{"body larger than drain limit", 1024, 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var conns int64
+			s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, body)
+			}))
+			s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt64(&conns, 1)
+				}
+			}
+			s.Start()
+			defer s.Close()
+
+			c, err := New(MaxDrainBytes(tc.drain))
+			if err != nil {
+				t.Fatalf("trouble when creating the client: %v", err)
+			}
+			defer c.Close()
+			for i := 0; i < 3; i++ {
+				err = c.Get(context.Background(), NoopResponseHandler, s.URL)
+				if err != nil {
+					t.Fatalf("trouble when making GET request: %v", err)
+				}
+			}
+			if n := atomic.LoadInt64(&conns); n != tc.expConns {
+				t.Errorf("expected %d connections, got %d", tc.expConns, n)
+			}
+		})
+	}
+}
+
+func TestDrainEndlessBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+				io.WriteString(w, "tick\n")
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer s.Close()
+
+	c, err := New()
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	done := make(chan error, 1)
+	go func() {
+		// the handler returns early, leaving the stream unread
+		done <- c.Get(context.Background(), NoopResponseHandler, s.URL)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("trouble when making GET request: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the drain of an endless stream to be given up")
+	}
+}
@@ -0,0 +1,304 @@
+// Package sigv4 signs requests with AWS Signature Version 4, for AWS-style
+// APIs and S3-compatible object stores, as an httpclient.Middleware.
+//
+//	signer := &sigv4.Signer{
+//		Region:      "us-east-1",
+//		Service:     "s3",
+//		Credentials: sigv4.StaticCredentials{AccessKeyID: id, SecretAccessKey: secret},
+//	}
+//	client, err := httpclient.New(httpclient.Use(sigv4.SignRequests(signer)))
+package sigv4
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+const (
+	// Algorithm of the Authorization header
+	Algorithm = "AWS4-HMAC-SHA256"
+	// UnsignedPayload is the X-Amz-Content-Sha256 header of requests whose
+	// payload is not signed
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+// Credentials sign the requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken of temporary credentials, sent as X-Amz-Security-Token
+	SessionToken string
+}
+
+// CredentialsProvider returns the Credentials signing a request. It is called
+// for every request, so that rotated credentials are used as soon as they are
+// available.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials is a CredentialsProvider of fixed Credentials
+type StaticCredentials Credentials
+
+// Credentials satisfies the CredentialsProvider interface
+func (c StaticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// FromCredentialProvider returns a CredentialsProvider of the Credential of
+// p, whose Username is the access key ID, Password the secret access key and
+// Token the session token, e.g. to use credentials rotated on disk with
+// httpclient.FileCredentials
+func FromCredentialProvider(p httpclient.CredentialProvider) CredentialsProvider {
+	return credentialsFunc(func(ctx context.Context) (Credentials, error) {
+		cred, err := p.Credential(ctx)
+		if err != nil {
+			return Credentials{}, err
+		}
+		return Credentials{AccessKeyID: cred.Username, SecretAccessKey: cred.Password, SessionToken: cred.Token}, nil
+	})
+}
+
+// credentialsFunc is like http.HandlerFunc, but for the CredentialsProvider
+// interface
+type credentialsFunc func(ctx context.Context) (Credentials, error)
+
+func (f credentialsFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// ignoredHeaders are not signed, as they may be changed on the way
+var ignoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// Signer signs requests for a service of a region
+type Signer struct {
+	Region      string
+	Service     string
+	Credentials CredentialsProvider
+	// UnsignedPayload leaves the payload out of the signature, so that it is
+	// not read before being sent, e.g. to stream uploads. Otherwise the body
+	// is read to be hashed, and replaced if it cannot be read again with
+	// GetBody.
+	UnsignedPayload bool
+
+	// offset is the clock skew with the service in nanoseconds, see
+	// SignRequests
+	offset int64
+	now    func() time.Time
+}
+
+// SignRequest sets the Authorization, X-Amz-Date and, if needed,
+// X-Amz-Security-Token headers of req. The X-Amz-Content-Sha256 header is set
+// for the s3 service or an unsigned payload, and used as hash of the payload
+// if it is set already. The path of s3 requests is not escaped twice.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if s.Credentials == nil {
+		return errors.New("sigv4: missing credentials")
+	}
+	creds, err := s.Credentials.Credentials(req.Context())
+	if err != nil {
+		return fmt.Errorf("sigv4: retrieving credentials: %w", err)
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	return s.sign(req, creds, now().Add(time.Duration(atomic.LoadInt64(&s.offset))))
+}
+
+func (s *Signer) sign(req *http.Request, creds Credentials, t time.Time) error {
+	t = t.UTC()
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", t.Format(timeFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		if s.UnsignedPayload {
+			payloadHash = UnsignedPayload
+		} else {
+			var err error
+			if payloadHash, err = hashBody(req); err != nil {
+				return err
+			}
+		}
+		if s.Service == "s3" || s.UnsignedPayload {
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req, s.Service != "s3"),
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{t.Format(dateFormat), s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{Algorithm, t.Format(timeFormat), scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{t.Format(dateFormat), s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// hashBody returns the hex encoded SHA-256 hash of the body of req
+func hashBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return hexSHA256(nil), nil
+	}
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return "", err
+		}
+	}
+	content, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return "", fmt.Errorf("sigv4: reading body: %w", err)
+	}
+	if req.GetBody == nil {
+		req.Body = io.NopCloser(bytes.NewReader(content))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		}
+	}
+	return hexSHA256(content), nil
+}
+
+// canonicalURI returns the escaped path of req, escaped once more unless
+// escapeTwice is false
+func canonicalURI(req *http.Request, escapeTwice bool) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if escapeTwice {
+		path = escape(path, false)
+	}
+	return path
+}
+
+// canonicalQuery returns the query parameters of req escaped and sorted by
+// key then value
+func canonicalQuery(req *http.Request) string {
+	var params [][2]string
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			params = append(params, [2]string{escape(k, true), escape(v, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	query := make([]string, len(params))
+	for i, p := range params {
+		query[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(query, "&")
+}
+
+// canonicalHeaders returns the signed header names and canonical headers of
+// req, which are its headers but the ignoredHeaders and the host
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": host(req)}
+	for k, vs := range req.Header {
+		name := strings.ToLower(k)
+		if ignoredHeaders[name] {
+			continue
+		}
+		values := make([]string, len(vs))
+		for i, v := range vs {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// host returns the host of req without default port
+func host(req *http.Request) string {
+	h := req.Host
+	if h == "" {
+		h = req.URL.Host
+	}
+	if hostname, port, err := net.SplitHostPort(h); err == nil {
+		if (port == "80" && req.URL.Scheme == "http") || (port == "443" && req.URL.Scheme == "https") {
+			return hostname
+		}
+	}
+	return h
+}
+
+// escape percent-encodes the characters of s but the unreserved ones of RFC
+// 3986, and '/' unless encodeSlash is set
+func escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
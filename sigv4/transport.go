@@ -0,0 +1,90 @@
+package sigv4
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gadventures/httpclient"
+)
+
+// MaxClockSkew is how far the clock of the client may be from the one of the
+// service before the signing time is corrected
+const MaxClockSkew = time.Minute
+
+// maxDrainBytes of a rejected response read before it is closed
+const maxDrainBytes = 4 << 10
+
+// SignRequests returns a Middleware signing the requests with s, see
+// Signer.SignRequest. Requests failing to be signed are not sent. The
+// Authorization and X-Amz-Security-Token headers are part of
+// httpclient.DefaultRedactedHeaders.
+//
+// When a request is rejected with 400 Bad Request or 403 Forbidden while
+// the Date header of the response is more than MaxClockSkew away from the
+// local clock, the signing time of s is corrected by the difference and the
+// request is signed and sent once more, if its body can be sent again, which
+// the OnRetry hooks of the client see.
+func SignRequests(s *Signer) httpclient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			signed := req.Clone(req.Context())
+			if err := s.SignRequest(signed); err != nil {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, err
+			}
+			res, err := next.RoundTrip(signed)
+			if err != nil || !s.correctClock(res) {
+				return res, err
+			}
+			if signed.Body != nil && signed.Body != http.NoBody && signed.GetBody == nil {
+				return res, nil
+			}
+
+			retry := req.Clone(req.Context())
+			if signed.GetBody != nil {
+				body, err := signed.GetBody()
+				if err != nil {
+					return res, nil
+				}
+				retry.Body, retry.GetBody = body, signed.GetBody
+			}
+			if err := s.SignRequest(retry); err != nil {
+				if retry.Body != nil {
+					retry.Body.Close()
+				}
+				return res, nil
+			}
+			io.CopyN(io.Discard, res.Body, maxDrainBytes)
+			res.Body.Close()
+			httpclient.NotifyRetry(retry)
+			return next.RoundTrip(retry)
+		})
+	}
+}
+
+// correctClock returns true if res rejected a request signed at a time too
+// far from the clock of the service, after correcting the signing time of s
+func (s *Signer) correctClock(res *http.Response) bool {
+	if res.StatusCode != http.StatusBadRequest && res.StatusCode != http.StatusForbidden {
+		return false
+	}
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	offset := date.Sub(now())
+	current := time.Duration(atomic.LoadInt64(&s.offset))
+	if skew := offset - current; skew > -MaxClockSkew && skew < MaxClockSkew {
+		return false
+	}
+	atomic.StoreInt64(&s.offset, int64(offset))
+	return true
+}
@@ -0,0 +1,113 @@
+package httpclient
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of the connections dialed by a Client. The totals are
+// broken down per host in Hosts, which is keyed by the dialed address
+// (host:port).
+//
+// Connections are only counted as Active while a request made through
+// Client.Do (or Get, Post) is using them, requests made directly with the
+// *http.Client returned by Client.Client count towards Idle.
+type Stats struct {
+	ConnStats
+	Hosts map[string]ConnStats
+}
+
+// ConnStats are the connection counters of a Client or a single host
+type ConnStats struct {
+	// Open connections, the sum of Active and Idle
+	Open int64
+	// Active connections currently serving a request
+	Active int64
+	// Idle connections currently open but not serving a request
+	Idle int64
+	// Closed connections since the Client was created
+	Closed int64
+	// Dials attempted since the Client was created
+	Dials int64
+	// DialFailures is the number of Dials that returned an error
+	DialFailures int64
+	// BytesRead from all connections
+	BytesRead int64
+	// BytesWritten to all connections
+	BytesWritten int64
+}
+
+// add the counters of o to s
+func (s *ConnStats) add(o ConnStats) {
+	s.Open += o.Open
+	s.Active += o.Active
+	s.Idle += o.Idle
+	s.Closed += o.Closed
+	s.Dials += o.Dials
+	s.DialFailures += o.DialFailures
+	s.BytesRead += o.BytesRead
+	s.BytesWritten += o.BytesWritten
+}
+
+// hostStats are the live counters for a single host, updated atomically
+type hostStats struct {
+	open         int64
+	active       int64
+	closed       int64
+	dials        int64
+	dialFailures int64
+	bytesRead    int64
+	bytesWritten int64
+}
+
+func (h *hostStats) snapshot() ConnStats {
+	s := ConnStats{
+		Open:         atomic.LoadInt64(&h.open),
+		Active:       atomic.LoadInt64(&h.active),
+		Closed:       atomic.LoadInt64(&h.closed),
+		Dials:        atomic.LoadInt64(&h.dials),
+		DialFailures: atomic.LoadInt64(&h.dialFailures),
+		BytesRead:    atomic.LoadInt64(&h.bytesRead),
+		BytesWritten: atomic.LoadInt64(&h.bytesWritten),
+	}
+	s.Idle = s.Open - s.Active
+	return s
+}
+
+// connStats keeps the hostStats of every host the client dialed
+type connStats struct {
+	mu    sync.Mutex
+	hosts map[string]*hostStats
+}
+
+// host returns the counters for addr, creating them on first use
+func (p *connStats) host(addr string) *hostStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts == nil {
+		p.hosts = make(map[string]*hostStats)
+	}
+	h, ok := p.hosts[addr]
+	if !ok {
+		h = new(hostStats)
+		p.hosts[addr] = h
+	}
+	return h
+}
+
+func (p *connStats) snapshot() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := Stats{Hosts: make(map[string]ConnStats, len(p.hosts))}
+	for addr, h := range p.hosts {
+		hs := h.snapshot()
+		s.Hosts[addr] = hs
+		s.add(hs)
+	}
+	return s
+}
+
+// Stats returns a snapshot of the Client's connection counters
+func (c *client) Stats() Stats {
+	return c.connStats.snapshot()
+}
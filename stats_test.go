@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	c, err := New()
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+
+	rh := func(ctx context.Context, resp *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		st := c.Stats().Hosts[u.Host]
+		if st.Active != 1 || st.Idle != 0 {
+			t.Errorf("expected 1 active and 0 idle conns during request, got %+v", st)
+		}
+		return nil
+	}
+	for i := 0; i < 3; i++ {
+		if err := c.Get(context.Background(), rh, s.URL); err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+	}
+
+	st := c.Stats()
+	hs, ok := st.Hosts[u.Host]
+	if !ok {
+		t.Fatalf("missing stats for host %s: %+v", u.Host, st)
+	}
+	if hs.Dials != 1 || hs.Open != 1 || hs.Idle != 1 || hs.Active != 0 || hs.Closed != 0 {
+		t.Errorf("unexpected stats after requests: %+v", hs)
+	}
+	if hs.BytesRead == 0 || hs.BytesWritten == 0 {
+		t.Errorf("expected bytes to be counted: %+v", hs)
+	}
+	if st.ConnStats != hs {
+		t.Errorf("expected totals %+v to equal the only host %+v", st.ConnStats, hs)
+	}
+
+	c.Close()
+	hs = c.Stats().Hosts[u.Host]
+	if hs.Open != 0 || hs.Closed != 1 {
+		t.Errorf("unexpected stats after Close: %+v", hs)
+	}
+
+	// dialing a port nobody listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if err := c.Get(context.Background(), NoopResponseHandler, "http://"+addr); err == nil {
+		t.Error("Expected an error but got nothing")
+	}
+	if hs := c.Stats().Hosts[addr]; hs.Dials != 1 || hs.DialFailures != 1 || hs.Open != 0 {
+		t.Errorf("unexpected stats after failed dial: %+v", hs)
+	}
+}
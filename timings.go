@@ -0,0 +1,210 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the breakdown of the time spent on a request made with Client.Do.
+// Phases that did not happen, e.g. DNS and Connect on a reused connection,
+// are zero. When following redirects the phases are those of the last
+// request, while Total covers all of them.
+type Timings struct {
+	// DNS lookup of the host
+	DNS time.Duration
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration
+	// TLSHandshake with the host
+	TLSHandshake time.Duration
+	// RequestWrite is the time from getting a connection to having written
+	// the request headers and body
+	RequestWrite time.Duration
+	// ServerProcessing is the time from writing the request to receiving the
+	// first response byte (TTFB)
+	ServerProcessing time.Duration
+	// ContentTransfer is the time from the first response byte to reading the
+	// body to EOF or closing it, whichever comes first. It keeps growing while
+	// the body is still being read.
+	ContentTransfer time.Duration
+	// Total time since Client.Do was called
+	Total time.Duration
+
+	// Reused is true if the request was sent on a pooled connection
+	Reused bool
+	// ConnID is the ID assigned to the connection when it was dialed, it
+	// matches the IDs in the client's logs and ConnCloseEvents
+	ConnID int64
+}
+
+// timingsKey is the context key of a request's timer
+type timingsKey struct{}
+
+// onTimingsKey is the context key of the OnTimings callbacks
+type onTimingsKey struct{}
+
+// ResponseTimings returns the Timings of a request made with Client.Do, for
+// use in a ResponseHandler. Content transfer is timed until the handler reads
+// the body to EOF.
+func ResponseTimings(res *http.Response) (Timings, bool) {
+	if res == nil || res.Request == nil {
+		return Timings{}, false
+	}
+	t, ok := res.Request.Context().Value(timingsKey{}).(*timer)
+	if !ok {
+		return Timings{}, false
+	}
+	return t.timings(), true
+}
+
+// OnTimings is a RequestOption registering f to be called with the Timings of
+// the request once Client.Do is done with it, i.e. after the ResponseHandler
+// returned and the body was closed.
+func OnTimings(f func(Timings)) RequestOption {
+	return func(req *http.Request) error {
+		ctx := req.Context()
+		fs, _ := ctx.Value(onTimingsKey{}).([]func(Timings))
+		fs = append(fs[:len(fs):len(fs)], f)
+		*req = *req.WithContext(context.WithValue(ctx, onTimingsKey{}, fs))
+		return nil
+	}
+}
+
+// timer collects the timestamps of a request's phases from httptrace hooks
+type timer struct {
+	mu                               sync.Mutex
+	start                            time.Time
+	dnsStart, dnsDone                time.Time
+	connectStart, connectDone        time.Time
+	tlsStart, tlsDone                time.Time
+	gotConn, wroteRequest, firstByte time.Time
+	bodyDone                         time.Time
+	reused                           bool
+	connID                           int64
+}
+
+func newTimer() *timer {
+	return &timer{start: time.Now()}
+}
+
+// set the timestamp pointed to by ts to now
+func (t *timer) set(ts *time.Time) {
+	now := time.Now()
+	t.mu.Lock()
+	*ts = now
+	t.mu.Unlock()
+}
+
+func (t *timer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// a new request of a redirect chain, start over
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.gotConn, t.wroteRequest, t.firstByte = time.Time{}, time.Time{}, time.Time{}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// several addresses may be tried, time from the first
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone:       func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			var connID int64
+			if cw, ok := unwrapConn(info.Conn); ok {
+				connID = cw.id
+			}
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = time.Now()
+			t.reused = info.Reused
+			t.connID = connID
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+// between returns the duration from start to end, zero if either is missing
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func (t *timer) timings() Timings {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transferEnd := t.bodyDone
+	if transferEnd.IsZero() {
+		transferEnd = now
+	}
+	return Timings{
+		DNS:              between(t.dnsStart, t.dnsDone),
+		Connect:          between(t.connectStart, t.connectDone),
+		TLSHandshake:     between(t.tlsStart, t.tlsDone),
+		RequestWrite:     between(t.gotConn, t.wroteRequest),
+		ServerProcessing: between(t.wroteRequest, t.firstByte),
+		ContentTransfer:  between(t.firstByte, transferEnd),
+		Total:            now.Sub(t.start),
+		Reused:           t.reused,
+		ConnID:           t.connID,
+	}
+}
+
+// report calls the OnTimings callbacks registered on the request
+func (t *timer) report(req *http.Request) {
+	fs, _ := req.Context().Value(onTimingsKey{}).([]func(Timings))
+	if len(fs) == 0 {
+		return
+	}
+	timings := t.timings()
+	for _, f := range fs {
+		f(timings)
+	}
+}
+
+// body wraps a response body to time the end of the content transfer
+func (t *timer) body(rc io.ReadCloser) io.ReadCloser {
+	return &timedBody{ReadCloser: rc, t: t}
+}
+
+type timedBody struct {
+	io.ReadCloser
+	t    *timer
+	once sync.Once
+}
+
+func (b *timedBody) done() {
+	b.once.Do(func() { b.t.set(&b.t.bodyDone) })
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
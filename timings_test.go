@@ -0,0 +1,69 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "hello")
+	}))
+	defer s.Close()
+
+	c, err := New()
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+
+	for i, reused := range []bool{false, true} {
+		var inHandler, reported Timings
+		var calls int
+		rh := func(ctx context.Context, resp *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			var ok bool
+			if inHandler, ok = ResponseTimings(resp); !ok {
+				t.Error("expected timings for the response")
+			}
+			_, err = io.Copy(io.Discard, resp.Body)
+			return err
+		}
+		err := c.Get(context.Background(), rh, s.URL, OnTimings(func(t Timings) {
+			calls++
+			reported = t
+		}))
+		if err != nil {
+			t.Fatalf("trouble when making GET request: %v", err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected OnTimings to be called once, got %d", calls)
+		}
+		if reported.Reused != reused || reported.ConnID != 1 {
+			t.Errorf("request %d: expected reused %t on conn 1, got %+v", i, reused, reported)
+		}
+		if reused != (reported.Connect == 0) {
+			t.Errorf("request %d: expected connect time only for a new conn, got %s", i, reported.Connect)
+		}
+		if reported.ServerProcessing < 20*time.Millisecond {
+			t.Errorf("request %d: expected server processing of at least 20ms, got %s", i, reported.ServerProcessing)
+		}
+		if reported.Total < reported.ServerProcessing+reported.ContentTransfer {
+			t.Errorf("request %d: phases exceed the total: %+v", i, reported)
+		}
+		if inHandler.ServerProcessing != reported.ServerProcessing || inHandler.Total > reported.Total {
+			t.Errorf("request %d: handler timings %+v disagree with %+v", i, inHandler, reported)
+		}
+	}
+
+	if _, ok := ResponseTimings(&http.Response{Request: httptest.NewRequest("GET", "/", nil)}); ok {
+		t.Error("expected no timings for a request not made by the client")
+	}
+}
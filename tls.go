@@ -0,0 +1,155 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// DefaultCertificatePollInterval is how often the files of a client
+// certificate are read again to pick up a renewed certificate
+const DefaultCertificatePollInterval = 10 * time.Second
+
+// certReloader loads the client certificate presented in TLS handshakes,
+// reading its files again once per poll interval, or once as soon as it
+// expired, and parsing them when their content changed
+type certReloader struct {
+	files    []string
+	parse    func(contents [][]byte) (*tls.Certificate, error)
+	interval time.Duration
+	logger   *slog.Logger // of the client, set by tlsClientConfig
+
+	mu       sync.Mutex // guards below
+	contents [][]byte
+	cert     *tls.Certificate
+	checked  time.Time
+}
+
+// newCertReloader returns a certReloader of the given files, which are loaded
+// once to fail early
+func newCertReloader(parse func(contents [][]byte) (*tls.Certificate, error), files ...string) (*certReloader, error) {
+	r := &certReloader{files: files, parse: parse, interval: DefaultCertificatePollInterval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the files, parsing them if they changed. It must be called
+// with r.mu held.
+func (r *certReloader) reload() error {
+	r.checked = time.Now()
+	contents := make([][]byte, len(r.files))
+	changed := r.cert == nil
+	for i, f := range r.files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("reading client certificate: %w", err)
+		}
+		contents[i] = b
+		changed = changed || !bytes.Equal(b, r.contents[i])
+	}
+	if !changed {
+		return nil
+	}
+	cert, err := r.parse(contents)
+	if err != nil {
+		return fmt.Errorf("loading client certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+	}
+	r.contents, r.cert = contents, cert
+	return nil
+}
+
+// getClientCertificate satisfies tls.Config.GetClientCertificate. The last
+// certificate loaded is kept while the files cannot be loaded, e.g. in the
+// middle of a renewal, and the failure is logged.
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	expiry := r.cert.Leaf.NotAfter
+	if now.Sub(r.checked) >= r.interval || (now.After(expiry) && r.checked.Before(expiry)) {
+		if err := r.reload(); err != nil && r.logger != nil {
+			r.logger.Warn(LogCertReloadFailed,
+				slog.Time(LogKeyNotAfter, expiry),
+				slog.String(LogKeyError, err.Error()))
+		}
+	}
+	return r.cert, nil
+}
+
+// parseX509KeyPair parses the PEM encoded certificate chain and private key
+func parseX509KeyPair(contents [][]byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// parsePKCS12 returns a parser of PKCS#12 bundles encrypted with password
+func parsePKCS12(password string) func(contents [][]byte) (*tls.Certificate, error) {
+	return func(contents [][]byte) (*tls.Certificate, error) {
+		key, leaf, chain, err := pkcs12.DecodeChain(contents[0], password)
+		if err != nil {
+			return nil, err
+		}
+		cert := &tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key, Leaf: leaf}
+		for _, ca := range chain {
+			cert.Certificate = append(cert.Certificate, ca.Raw)
+		}
+		return cert, nil
+	}
+}
+
+// loadCertPool returns a pool of the certificates of the PEM encoded files
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no CA certificate file")
+	}
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificate in %s", f)
+		}
+	}
+	return pool, nil
+}
+
+// tlsClientConfig returns the TLS configuration of the transport, nil if it
+// is the default one
+func (c *client) tlsClientConfig() *tls.Config {
+	if c.tlsConfig == nil && c.rootCAs == nil && c.clientCert == nil {
+		return nil
+	}
+	cfg := c.tlsConfig.Clone()
+	if cfg == nil {
+		cfg = new(tls.Config)
+	}
+	if c.rootCAs != nil {
+		cfg.RootCAs = c.rootCAs
+	}
+	if c.clientCert != nil {
+		c.clientCert.logger = c.logger
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = c.clientCert.getClientCertificate
+	}
+	return cfg
+}
@@ -0,0 +1,227 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testCA issues client certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("trouble when creating the CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a client certificate of the given common name
+func (ca *testCA) issue(t *testing.T, cn string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("trouble when issuing the certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// writePEM writes the certificate and key of cn to certFile and keyFile
+func (ca *testCA) writePEM(t *testing.T, cn, certFile, keyFile string) {
+	cert, key := ca.issue(t, cn)
+	der, _ := x509.MarshalECPrivateKey(key)
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: cert.Raw},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: der},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("trouble when writing %s: %v", file, err)
+		}
+	}
+}
+
+// newMTLSServer returns a server requiring client certificates of ca, which
+// answers with their common name, and the file of its own certificate
+func newMTLSServer(t *testing.T, ca *testCA) (*httptest.Server, string) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	s.Config.ErrorLog = log.New(io.Discard, "", 0)
+	s.StartTLS()
+	t.Cleanup(s.Close)
+
+	caFile := filepath.Join(t.TempDir(), "server-ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("trouble when writing the server CA: %v", err)
+	}
+	return s, caFile
+}
+
+func getCommonName(c Client, url string) (string, error) {
+	var cn string
+	rh := func(ctx context.Context, res *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(res.Body)
+		cn = string(b)
+		return err
+	}
+	err := c.Get(context.Background(), rh, url)
+	return cn, err
+}
+
+func TestClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	s, serverCA := newMTLSServer(t, ca)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca.writePEM(t, "client-1", certFile, keyFile)
+
+	c, err := New(RootCAs(serverCA), ClientCertificate(certFile, keyFile), DisableKeepAlive())
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	c.(*client).clientCert.interval = 10 * time.Millisecond
+	if cn, err := getCommonName(c, s.URL); err != nil || cn != "client-1" {
+		t.Fatalf("expected client-1, got %q, %v", cn, err)
+	}
+
+	// renewed on disk
+	ca.writePEM(t, "client-2", certFile, keyFile)
+	time.Sleep(20 * time.Millisecond)
+	if cn, err := getCommonName(c, s.URL); err != nil || cn != "client-2" {
+		t.Errorf("expected the renewed client-2, got %q, %v", cn, err)
+	}
+
+	// the last certificate is kept in the middle of a renewal
+	os.Remove(keyFile)
+	time.Sleep(20 * time.Millisecond)
+	if cn, err := getCommonName(c, s.URL); err != nil || cn != "client-2" {
+		t.Errorf("expected the last client-2, got %q, %v", cn, err)
+	}
+
+	// rejected without certificate
+	plain, err := New(RootCAs(serverCA))
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer plain.Close()
+	if _, err := getCommonName(plain, s.URL); err == nil {
+		t.Error("expected an error without client certificate")
+	}
+
+	// reloaded once after expiry, then once per poll interval
+	var logs bytes.Buffer
+	r := c.(*client).clientCert
+	r.mu.Lock()
+	r.interval, r.checked = time.Hour, time.Now().Add(-time.Minute)
+	r.cert.Leaf.NotAfter = time.Now().Add(-time.Second)
+	r.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	r.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		if cert, err := r.getClientCertificate(nil); err != nil || cert.Leaf.Subject.CommonName != "client-2" {
+			t.Errorf("expected the last client-2, got %v", err)
+		}
+	}
+	if n := strings.Count(logs.String(), LogCertReloadFailed); n != 1 {
+		t.Errorf("expected a single failed reload to be logged, got %d:\n%s", n, logs.String())
+	}
+
+	for _, opt := range []Option{
+		ClientCertificate(certFile, keyFile), // removed key
+		ClientCertificate(keyFile, certFile),
+		RootCAs(),
+		RootCAs(keyFile),
+		TLSConfig(nil),
+	} {
+		if _, err := New(opt); !errors.Is(err, ErrInvalidOptionValue) {
+			t.Errorf("expected ErrInvalidOptionValue, got %v", err)
+		}
+	}
+}
+
+func TestClientCertificatePKCS12(t *testing.T) {
+	ca := newTestCA(t)
+	s, serverCA := newMTLSServer(t, ca)
+	cert, key := ca.issue(t, "bundle")
+	pfx, err := pkcs12.Modern.Encode(key, cert, []*x509.Certificate{ca.cert}, "s3cr3t")
+	if err != nil {
+		t.Fatalf("trouble when encoding the bundle: %v", err)
+	}
+	bundle := filepath.Join(t.TempDir(), "client.p12")
+	if err := os.WriteFile(bundle, pfx, 0600); err != nil {
+		t.Fatalf("trouble when writing the bundle: %v", err)
+	}
+
+	c, err := New(
+		RootCAs(serverCA),
+		ClientCertificatePKCS12(bundle, "s3cr3t"),
+		TLSConfig(&tls.Config{MinVersion: tls.VersionTLS13}),
+	)
+	if err != nil {
+		t.Fatalf("trouble when creating the client: %v", err)
+	}
+	defer c.Close()
+	if cn, err := getCommonName(c, s.URL); err != nil || cn != "bundle" {
+		t.Errorf("expected bundle, got %q, %v", cn, err)
+	}
+	if cfg := c.(*client).transport.TLSClientConfig; cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected the TLS configuration to be used, got %+v", cfg)
+	}
+
+	if _, err := New(ClientCertificatePKCS12(bundle, "wrong")); !errors.Is(err, ErrInvalidOptionValue) {
+		t.Errorf("expected ErrInvalidOptionValue, got %v", err)
+	}
+	if _, err := New(RootCAs(serverCA), WithRoundTripper(http.DefaultTransport)); !errors.Is(err, ErrInvalidOptionValue) {
+		t.Errorf("expected ErrInvalidOptionValue with WithRoundTripper, got %v", err)
+	}
+}
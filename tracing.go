@@ -0,0 +1,302 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingOption configures the OpenTelemetry instrumentation enabled by the
+// WithTracing option
+type TracingOption func(*tracingConfig)
+
+type tracingConfig struct {
+	tracerProvider    trace.TracerProvider
+	propagators       propagation.TextMapPropagator
+	spanNameFormatter func(*http.Request) string
+	filters           []func(*http.Request) bool
+	attributes        []func(*http.Request) []attribute.KeyValue
+	connectionSpans   bool
+	redact            *redactor
+}
+
+// TracerProvider sets the provider of the tracer used to create spans. By
+// default the global TracerProvider is used.
+func TracerProvider(tp trace.TracerProvider) TracingOption {
+	return func(tc *tracingConfig) {
+		tc.tracerProvider = tp
+	}
+}
+
+// Propagators sets the propagators used to inject the span context into the
+// request headers. By default the global TextMapPropagator is used.
+func Propagators(p propagation.TextMapPropagator) TracingOption {
+	return func(tc *tracingConfig) {
+		tc.propagators = p
+	}
+}
+
+// SpanNameFormatter changes how spans are named. The default is the request
+// method followed by the Route given to the request, or "HTTP <method>" when
+// no Route was given. Avoid using the raw URL, it leads to a span name per
+// resource.
+func SpanNameFormatter(f func(*http.Request) string) TracingOption {
+	return func(tc *tracingConfig) {
+		tc.spanNameFormatter = f
+	}
+}
+
+// TracingFilter excludes requests from tracing, e.g. health checks. Requests
+// for which f returns false are sent without a span. Filters may be given
+// several times, a request is traced only if all of them return true.
+func TracingFilter(f func(*http.Request) bool) TracingOption {
+	return func(tc *tracingConfig) {
+		tc.filters = append(tc.filters, f)
+	}
+}
+
+// SpanAttributes adds the attributes returned by f to the span of every
+// request. The request carries the context passed to Client.Do, so this can
+// be used to add e.g. tenant or user attributes.
+func SpanAttributes(f func(*http.Request) []attribute.KeyValue) TracingOption {
+	return func(tc *tracingConfig) {
+		tc.attributes = append(tc.attributes, f)
+	}
+}
+
+// ConnectionSpans adds child spans for the DNS lookup, the TCP dial (tagged
+// with the connection ID) and the TLS handshake to the span of every request,
+// as well as events for getting a connection (new or reused, and for how long
+// it was idle), writing the request and receiving the first response byte.
+// This tells apart time spent setting up the connection from time spent by the
+// server.
+func ConnectionSpans() TracingOption {
+	return func(tc *tracingConfig) {
+		tc.connectionSpans = true
+	}
+}
+
+// routeKey is the context key of the Route request option
+type routeKey struct{}
+
+// Route is a RequestOption naming the route template of the request, e.g.
+// "/users/{id}". It is used to name the request's span without the cardinality
+// of the raw URL.
+func Route(template string) RequestOption {
+	return func(req *http.Request) error {
+		*req = *req.WithContext(context.WithValue(req.Context(), routeKey{}, template))
+		return nil
+	}
+}
+
+// RouteFromContext returns the route template given with the Route request
+// option, if any
+func RouteFromContext(ctx context.Context) (string, bool) {
+	route, ok := ctx.Value(routeKey{}).(string)
+	return route, ok
+}
+
+// defaultSpanName is the default SpanNameFormatter
+func defaultSpanName(req *http.Request) string {
+	if route, ok := RouteFromContext(req.Context()); ok {
+		return req.Method + " " + route
+	}
+	return "HTTP " + req.Method
+}
+
+// traced reports whether the filters allow the request to be traced
+func (tc *tracingConfig) traced(req *http.Request) bool {
+	for _, f := range tc.filters {
+		if !f(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// transport wraps next with the otelhttp instrumentation
+func (tc *tracingConfig) transport(next http.RoundTripper) http.RoundTripper {
+	next = &spanAttributesTransport{next, tc}
+	nameFormatter := tc.spanNameFormatter
+	if nameFormatter == nil {
+		nameFormatter = defaultSpanName
+	}
+	opts := []otelhttp.Option{
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return nameFormatter(req)
+		}),
+	}
+	if tc.tracerProvider != nil {
+		opts = append(opts, otelhttp.WithTracerProvider(tc.tracerProvider))
+	}
+	if tc.propagators != nil {
+		opts = append(opts, otelhttp.WithPropagators(tc.propagators))
+	}
+	if len(tc.filters) > 0 {
+		opts = append(opts, otelhttp.WithFilter(tc.traced))
+	}
+	if tc.connectionSpans {
+		opts = append(opts, otelhttp.WithClientTrace(tc.clientTrace))
+	}
+	return otelhttp.NewTransport(next, opts...)
+}
+
+// tracer returns the tracer for spans started below the span in ctx, the same
+// way otelhttp picks its tracer
+func (tc *tracingConfig) tracer(ctx context.Context) trace.Tracer {
+	tp := tc.tracerProvider
+	if tp == nil {
+		if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+			tp = span.TracerProvider()
+		} else {
+			tp = otel.GetTracerProvider()
+		}
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+// startDialSpan starts the span of a TCP dial made by dialContext, if
+// ConnectionSpans are enabled
+func (tc *tracingConfig) startDialSpan(ctx context.Context, connID int64, network, addr string) (context.Context, trace.Span) {
+	if tc == nil || !tc.connectionSpans || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx, nil
+	}
+	return tc.tracer(ctx).Start(ctx, "httpclient.dial",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int64("httpclient.conn.id", connID),
+			attribute.String("network.transport", network),
+			attribute.String("net.peer.name", addr),
+		),
+	)
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// clientTrace returns the httptrace hooks creating the connection level spans
+// and events below the request span in ctx
+func (tc *tracingConfig) clientTrace(ctx context.Context) *httptrace.ClientTrace {
+	tracer := tc.tracer(ctx)
+	requestSpan := trace.SpanFromContext(ctx)
+	var (
+		mu           sync.Mutex // guards the spans, hooks may run concurrently
+		dnsSpan      trace.Span
+		tlsSpan      trace.Span
+		wroteRequest time.Time
+	)
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			_, span := tracer.Start(ctx, "httpclient.dns",
+				trace.WithAttributes(attribute.String("net.host.name", info.Host)))
+			mu.Lock()
+			dnsSpan = span
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			span := dnsSpan
+			mu.Unlock()
+			if span == nil {
+				return
+			}
+			addrs := make([]string, 0, len(info.Addrs))
+			for _, addr := range info.Addrs {
+				addrs = append(addrs, addr.String())
+			}
+			span.SetAttributes(attribute.StringSlice("net.host.addresses", addrs))
+			endSpan(span, info.Err)
+		},
+		TLSHandshakeStart: func() {
+			_, span := tracer.Start(ctx, "httpclient.tls_handshake")
+			mu.Lock()
+			tlsSpan = span
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			span := tlsSpan
+			mu.Unlock()
+			if span == nil {
+				return
+			}
+			span.SetAttributes(
+				attribute.String("tls.server_name", state.ServerName),
+				attribute.Bool("tls.resumed", state.DidResume),
+				attribute.String("tls.protocol.version", tls.VersionName(state.Version)),
+			)
+			endSpan(span, err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			attrs := []attribute.KeyValue{
+				attribute.Bool("httpclient.conn.reused", info.Reused),
+				attribute.Bool("httpclient.conn.was_idle", info.WasIdle),
+			}
+			if info.WasIdle {
+				attrs = append(attrs, attribute.Int64("httpclient.conn.idle_time_ms", info.IdleTime.Milliseconds()))
+			}
+			if cw, ok := unwrapConn(info.Conn); ok {
+				attrs = append(attrs, attribute.Int64("httpclient.conn.id", cw.id))
+			}
+			requestSpan.AddEvent("httpclient.got_conn", trace.WithAttributes(attrs...))
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			mu.Lock()
+			wroteRequest = time.Now()
+			mu.Unlock()
+			if info.Err != nil {
+				requestSpan.RecordError(info.Err)
+			}
+			requestSpan.AddEvent("httpclient.wrote_request")
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			wrote := wroteRequest
+			mu.Unlock()
+			var attrs []attribute.KeyValue
+			if !wrote.IsZero() {
+				attrs = append(attrs, attribute.Int64("httpclient.server_time_ms", time.Since(wrote).Milliseconds()))
+			}
+			requestSpan.AddEvent("httpclient.first_response_byte", trace.WithAttributes(attrs...))
+		},
+	}
+}
+
+// spanAttributesTransport sets the SpanAttributes on the span started by
+// otelhttp, which is in the context of the request by the time it gets here.
+// It also replaces the URL recorded by otelhttp with its redacted form.
+type spanAttributesTransport struct {
+	next   http.RoundTripper
+	config *tracingConfig
+}
+
+func (t *spanAttributesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// filtered requests have no span of their own, don't touch the parent's
+	if t.config.traced(req) {
+		span := trace.SpanFromContext(req.Context())
+		if t.config.redact != nil {
+			span.SetAttributes(semconv.HTTPURL(t.config.redact.URL(req.URL)))
+		}
+		for _, f := range t.config.attributes {
+			span.SetAttributes(f(req)...)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,179 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tenantKey struct{}
+
+func TestTracingOptions(t *testing.T) {
+	spanExporter := tracetest.NewInMemoryExporter()
+	provider := trace.NewTracerProvider(trace.WithSyncer(spanExporter))
+	t.Cleanup(func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown tracer provider: %v", err)
+		}
+	})
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			h := r.Header.Get("Traceparent")
+			if r.URL.Path == "/health" && h != "" {
+				t.Errorf("expected no propagation header for filtered request")
+			}
+			if r.URL.Path != "/health" && h == "" {
+				t.Errorf("expected TraceContext propagation header in request")
+			}
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	client, err := New(WithTracing(
+		TracerProvider(provider),
+		Propagators(propagation.TraceContext{}),
+		TracingFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/health"
+		}),
+		SpanAttributes(func(r *http.Request) []attribute.KeyValue {
+			if tenant, ok := r.Context().Value(tenantKey{}).(string); ok {
+				return []attribute.KeyValue{attribute.String("tenant", tenant)}
+			}
+			return nil
+		}),
+	))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	for _, tc := range []struct {
+		path string
+		opts []RequestOption
+	}{
+		{"/health", nil},
+		{"/items/42", []RequestOption{Route("/items/{id}")}},
+		{"/items", nil},
+	} {
+		if err := client.Get(ctx, NoopResponseHandler, server.URL+tc.path, tc.opts...); err != nil {
+			t.Fatalf("make request: %v", err)
+		}
+	}
+
+	spans := spanExporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected to have two spans from the client, got %d: %v", len(spans), spans)
+	}
+	for i, expected := range []string{"GET /items/{id}", "HTTP GET"} {
+		if spans[i].Name != expected {
+			t.Errorf("expected span name %q, got %q", expected, spans[i].Name)
+		}
+		var tenant string
+		for _, a := range spans[i].Attributes {
+			if a.Key == "tenant" {
+				tenant = a.Value.AsString()
+			}
+		}
+		if tenant != "acme" {
+			t.Errorf("expected tenant attribute in span %q, got %+v", spans[i].Name, spans[i].Attributes)
+		}
+	}
+}
+
+func TestSpanNameFormatter(t *testing.T) {
+	spanExporter := tracetest.NewInMemoryExporter()
+	provider := trace.NewTracerProvider(trace.WithSyncer(spanExporter))
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	client, err := New(WithTracing(
+		TracerProvider(provider),
+		SpanNameFormatter(func(r *http.Request) string {
+			return "partner " + r.Method
+		}),
+	))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if err := client.Get(context.Background(), NoopResponseHandler, server.URL); err != nil {
+		t.Fatalf("make request: %v", err)
+	}
+	if spans := spanExporter.GetSpans(); len(spans) != 1 || spans[0].Name != "partner GET" {
+		t.Errorf(`expected a single "partner GET" span, got %v`, spans)
+	}
+}
+
+func TestConnectionSpans(t *testing.T) {
+	spanExporter := tracetest.NewInMemoryExporter()
+	provider := trace.NewTracerProvider(trace.WithSyncer(spanExporter))
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	// use a host name to have a DNS lookup
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	client, err := New(WithTracing(TracerProvider(provider), ConnectionSpans()))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	defer client.Close()
+	for i := 0; i < 2; i++ {
+		if err := client.Get(context.Background(), NoopResponseHandler, url); err != nil {
+			t.Fatalf("make request: %v", err)
+		}
+	}
+
+	byName := make(map[string][]tracetest.SpanStub)
+	for _, span := range spanExporter.GetSpans() {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	requests := byName["HTTP GET"]
+	if len(requests) != 2 || len(byName["httpclient.dns"]) != 1 || len(byName["httpclient.dial"]) != 1 {
+		t.Fatalf("expected 2 request spans, a dns and a dial span, got %v", byName)
+	}
+	for _, name := range []string{"httpclient.dns", "httpclient.dial"} {
+		span := byName[name][0]
+		if span.Parent.TraceID() != requests[0].SpanContext.TraceID() {
+			t.Errorf("expected %s span to be part of the first request's trace", name)
+		}
+	}
+	var connID int64
+	for _, a := range byName["httpclient.dial"][0].Attributes {
+		if a.Key == "httpclient.conn.id" {
+			connID = a.Value.AsInt64()
+		}
+	}
+	if connID != 1 {
+		t.Errorf("expected the dial span to carry conn id 1, got %d", connID)
+	}
+
+	for i, reused := range []bool{false, true} {
+		events := make(map[string]map[attribute.Key]attribute.Value)
+		for _, ev := range requests[i].Events {
+			attrs := make(map[attribute.Key]attribute.Value)
+			for _, a := range ev.Attributes {
+				attrs[a.Key] = a.Value
+			}
+			events[ev.Name] = attrs
+		}
+		gotConn, ok := events["httpclient.got_conn"]
+		if !ok {
+			t.Fatalf("missing got_conn event in request %d: %+v", i, requests[i].Events)
+		}
+		if gotConn["httpclient.conn.reused"].AsBool() != reused || gotConn["httpclient.conn.id"].AsInt64() != 1 {
+			t.Errorf("unexpected got_conn event in request %d: %v", i, gotConn)
+		}
+		for _, name := range []string{"httpclient.wrote_request", "httpclient.first_response_byte"} {
+			if _, ok := events[name]; !ok {
+				t.Errorf("missing %s event in request %d", name, i)
+			}
+		}
+	}
+}